@@ -1,14 +1,34 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/severedsea/golang-kit/envvar"
 	"github.com/severedsea/golang-kit/web/server"
 	"github.com/severedsea/jwt-server/cmd/serverd/banner"
 	"github.com/severedsea/jwt-server/cmd/serverd/router"
 	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"github.com/severedsea/jwt-server/internal/pkg/redis"
+	"github.com/severedsea/jwt-server/internal/service/auth"
+	"github.com/severedsea/jwt-server/internal/service/rotation"
+)
+
+const (
+	// rotationCheckInterval is how often each replica checks if the next key is due
+	rotationCheckInterval = time.Minute
+	// rotationReloadInterval is how often each replica reloads the rotated keys from redis
+	rotationReloadInterval = 30 * time.Second
+	// signerReloadInterval is how often the keys of the signing service are reloaded to pick up its key rotation
+	signerReloadInterval = time.Minute
+	// rotationStartupTimeout is how long to wait for another replica to publish the first key
+	rotationStartupTimeout = time.Minute
+	// jwksReloadInterval is how often a verify-only replica reloads the JWKS of the issuing replicas
+	jwksReloadInterval = 5 * time.Minute
 )
 
 func main() {
@@ -18,17 +38,117 @@ func main() {
 	envvarValidate()
 
 	// Auth
-	jwt.InitKeyFiles(
-		os.Getenv("JWT_PRIVATE_KEY_PATH"),
-		os.Getenv("JWT_PUBLIC_KEY_PATH"),
-	)
+	verifyOnly, err := jwt.VerifyOnlyFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "jwt"))
+	}
+
+	reloadInterval, _ := time.ParseDuration(os.Getenv("JWT_KEY_RELOAD_INTERVAL"))
+	var keySource jwt.KeySource
+	switch {
+	case verifyOnly:
+		s, err := jwt.VerifyOnlyKeySourceFromEnv()
+		if err != nil {
+			log.Fatalf("%s", errors.Wrap(err, "jwt"))
+		}
+		if err := jwt.InitKeySource(s); err != nil {
+			log.Fatalf("%s", errors.Wrap(err, "jwt"))
+		}
+		if _, ok := s.(jwt.JWKSKeySource); ok && reloadInterval == 0 {
+			reloadInterval = jwksReloadInterval
+		}
+		keySource = s
+
+	case os.Getenv("JWT_KEY_ROTATION_INTERVAL") != "":
+		if reloadInterval == 0 {
+			reloadInterval = rotationReloadInterval
+		}
+		keySource = initRotation(reloadInterval)
+
+	default:
+		s, err := jwt.KeySourceFromEnv()
+		if err != nil {
+			log.Fatalf("%s", errors.Wrap(err, "jwt"))
+		}
+		if err := jwt.InitKeySource(s); err != nil {
+			log.Fatalf("%s", errors.Wrap(err, "jwt"))
+		}
+		if _, ok := s.(jwt.RemoteKeySource); ok && reloadInterval == 0 {
+			reloadInterval = signerReloadInterval
+		}
+		keySource = s
+	}
+
+	// Reload keys on SIGHUP or on the polling interval
+	go jwt.NewReloader(jwt.DefaultKeyStore(), keySource, reloadInterval).Run(context.Background())
 
 	// Start server
-	s := server.New(fmt.Sprintf(":%s", envvar.Get("PORT", "3000")), router.Handler())
+	s := server.New(fmt.Sprintf(":%s", envvar.Get("PORT", "3000")), router.Handler(verifyOnly))
 	s.Start()
 }
 
+// initRotation starts the scheduled key rotation and loads the keys from redis
+// The keys are reloaded on the interval provided, which the retired keys are retained for on top of the token lifetime.
+func initRotation(reloadInterval time.Duration) jwt.KeySource {
+	rds, err := redis.New()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "redis"))
+	}
+
+	cfg, err := rotation.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "rotation"))
+	}
+	policy, err := jwt.ValidationPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "jwt"))
+	}
+	// Retired keys must stay verifiable until all the tokens they signed have expired
+	cfg.Retention = rotation.Retention(auth.TokenLifetime, policy.Leeway, reloadInterval, rotationCheckInterval)
+
+	rotator, err := rotation.New(rds, cfg)
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "rotation"))
+	}
+
+	ctx := context.Background()
+	if _, err := rotator.Rotate(ctx); err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "rotation"))
+	}
+
+	// Another replica may be holding the lock while publishing the first key
+	deadline := time.Now().Add(rotationStartupTimeout)
+	for {
+		err := jwt.InitKeySource(rotator)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			log.Fatalf("%s", errors.Wrap(err, "jwt"))
+		}
+		time.Sleep(time.Second)
+	}
+
+	go rotator.Run(ctx, rotationCheckInterval)
+
+	return rotator
+}
+
 func envvarValidate() {
-	envvar.ValidateEitherNotEmpty("JWT_PUBLIC_KEY_PATH", "JWT_PUBLIC_KEY")
-	envvar.ValidateEitherNotEmpty("JWT_PRIVATE_KEY_PATH", "JWT_PRIVATE_KEY")
+	// The public key is derived from the private key if not provided
+	// Verify-only replicas only need the public keys
+	envvar.ValidateEitherNotEmptyF("JWT_SIGNER_URL", "JWT_KEY_DIR", "JWT_PRIVATE_KEY_PATH", "JWT_PRIVATE_KEY", "JWT_KEY_ROTATION_INTERVAL",
+		"JWT_JWKS_URL", "JWT_PUBLIC_KEY_PATH", "JWT_PUBLIC_KEY")
+	if envvar.ValidateNotEmpty("JWT_KEY_RELOAD_INTERVAL") {
+		envvar.ValidateDurationF("JWT_KEY_RELOAD_INTERVAL")
+	}
+	for _, key := range []string{"JWT_LEEWAY", "JWT_MAX_TOKEN_AGE"} {
+		if envvar.ValidateNotEmpty(key) {
+			envvar.ValidateDurationF(key)
+		}
+	}
+	if envvar.ValidateNotEmpty("JWT_KEY_ROTATION_INTERVAL") {
+		envvar.ValidateDurationF("JWT_KEY_ROTATION_INTERVAL")
+		envvar.ValidateNotEmptyF("JWT_KEY_ENCRYPTION_KEY")
+	}
 }
@@ -13,3 +13,11 @@ func Router(r chi.Router) {
 	// Versioned routes
 	r.Group(v1.Router)
 }
+
+// VerifyOnlyRouter registers the handlers that do not issue tokens to the router provided in the argument
+func VerifyOnlyRouter(r chi.Router) {
+	// Middlewares
+
+	// Versioned routes
+	r.Group(v1.VerifyOnlyRouter)
+}
@@ -1,9 +1,12 @@
 package v1
 
 import (
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/severedsea/golang-kit/web"
+	"github.com/severedsea/jwt-server/internal/pkg/stringsx"
 	"github.com/severedsea/jwt-server/internal/service/auth"
 )
 
@@ -18,16 +21,60 @@ func NewAuthHandler(a AuthService) AuthHandler {
 }
 
 type TokenResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken string   `json:"access_token"`
+	Scope       string   `json:"scope,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Audience    string   `json:"audience,omitempty"`
+	// RefreshToken is single-use, the response of the refresh contains the next one
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func newTokenResponse(t auth.Token) TokenResponse {
+	return TokenResponse{
+		AccessToken:  t.AccessToken,
+		Scope:        t.Scope,
+		Roles:        t.Roles,
+		Audience:     t.Audience,
+		RefreshToken: t.RefreshToken,
+	}
 }
 
 // Login will generate an access_token for the provided subject and return as a session cookie
+// The optional scopes are space-delimited in `scope` and the optional roles are comma-separated in `roles`.
+// The optional `audience` restricts the token to one of the known audiences.
 func (h AuthHandler) Login() http.HandlerFunc {
 	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
 		ctx := r.Context()
 
-		subject := r.URL.Query().Get("subject")
-		token, err := h.auth.Login(ctx, subject)
+		q := r.URL.Query()
+		token, err := h.auth.Login(ctx, auth.LoginRequest{
+			Subject:   q.Get("subject"),
+			Scopes:    strings.Fields(q.Get("scope")),
+			Roles:     stringsx.SplitList(q.Get("roles")),
+			Audience:  q.Get("audience"),
+			UserAgent: r.UserAgent(),
+			IP:        clientIP(r),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Set token as cookie for web clients
+		http.SetCookie(w, token.Cookie())
+
+		web.RespondJSON(ctx, w, newTokenResponse(token), nil)
+
+		return nil
+	})
+}
+
+// Refresh will exchange the `refresh_token` form value for a new access_token and refresh_token of the same session
+// A refresh token can only be used once. Reusing it logs out the session.
+func (h AuthHandler) Refresh() http.HandlerFunc {
+	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+
+		token, err := h.auth.Refresh(ctx, r.PostFormValue("refresh_token"))
 		if err != nil {
 			return err
 		}
@@ -35,7 +82,7 @@ func (h AuthHandler) Login() http.HandlerFunc {
 		// Set token as cookie for web clients
 		http.SetCookie(w, token.Cookie())
 
-		web.RespondJSON(ctx, w, TokenResponse{AccessToken: token.AccessToken}, nil)
+		web.RespondJSON(ctx, w, newTokenResponse(token), nil)
 
 		return nil
 	})
@@ -60,7 +107,7 @@ func (h AuthHandler) Logout() http.HandlerFunc {
 			return err
 		}
 
-		if err := h.auth.Logout(ctx, claims.Subject); err != nil {
+		if err := h.auth.Logout(ctx, claims); err != nil {
 			return err
 		}
 
@@ -70,3 +117,28 @@ func (h AuthHandler) Logout() http.HandlerFunc {
 		return nil
 	})
 }
+
+// Revoke revokes the access_token in the `token` form value, as described in RFC 7009
+// The other tokens of the subject are still valid.
+func (h AuthHandler) Revoke() http.HandlerFunc {
+	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+
+		token := r.PostFormValue("token")
+		if token == "" {
+			return auth.ErrMissingToken
+		}
+
+		return h.auth.Revoke(ctx, token)
+	})
+}
+
+// clientIP returns the IP address of the client without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -2,25 +2,82 @@
 package v1
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	goredis "github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
 	"github.com/severedsea/jwt-server/internal/pkg/redis"
 	"github.com/severedsea/jwt-server/internal/service/auth"
 )
 
 var (
-	redisClient goredis.Cmdable
+	store       auth.SessionStore
+	grants      auth.GrantStore
+	audiences   []string
+	audience    string
+	verifier    *jwt.Verifier
+	maxSessions int
+	refreshTTL  time.Duration
+	sessionTTL  time.Duration
+	idleTimeout time.Duration
+	hashKey     []byte
+	verifyCache *auth.VerifyCache
 )
 
 func init() {
 	var err error
-	redisClient, err = redis.New()
+	store, err = auth.StoreFromEnv(redis.New)
 	if err != nil {
-		log.Fatalf("%s", errors.Wrap(err, "redis"))
+		log.Fatalf("%s", errors.Wrap(err, "sessions"))
 	}
+
+	grants, err = auth.GrantsFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "grants"))
+	}
+
+	policy, err := jwt.ValidationPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "jwt"))
+	}
+	verifier = jwt.DefaultVerifier().WithPolicy(policy)
+
+	maxSessions, err = auth.MaxSessionsFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "sessions"))
+	}
+
+	refreshTTL, err = auth.RefreshTokenLifetimeFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "refresh token"))
+	}
+
+	sessionTTL, err = auth.SessionLifetimeFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "sessions"))
+	}
+
+	idleTimeout, err = auth.IdleTimeoutFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "sessions"))
+	}
+
+	verifyCache, err = auth.VerifyCacheFromEnv(store)
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "verify cache"))
+	}
+	go verifyCache.Run(context.Background())
+
+	hashKey, err = auth.TokenHashKeyFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "token hash"))
+	}
+
+	audiences = auth.KnownAudiencesFromEnv()
+	audience = auth.AudienceFromEnv()
 }
 
 // Router registers handlers to the router provided in the argument
@@ -29,17 +86,28 @@ func Router(r chi.Router) {
 	r.Group(authenticated)
 }
 
+// VerifyOnlyRouter registers the handlers that do not issue tokens to the router provided in the argument
+func VerifyOnlyRouter(r chi.Router) {
+	r.Group(authenticated)
+}
+
 func public(r chi.Router) {
 
-	authSvc := auth.New(redisClient)
+	authSvc := auth.New(store, auth.WithVerifier(verifier), auth.WithGrants(grants), auth.WithKnownAudiences(audiences...),
+		auth.WithMaxSessions(maxSessions), auth.WithRefreshTokenLifetime(refreshTTL), auth.WithSessionLifetime(sessionTTL),
+		auth.WithIdleTimeout(idleTimeout), auth.WithTokenHashKey(hashKey), auth.WithVerifyCache(verifyCache))
 	a := NewAuthHandler(authSvc)
 
 	r.Get("/v1/login", a.Login())
+	r.Post("/v1/token/refresh", a.Refresh())
+	r.Post("/v1/revoke", a.Revoke())
 
 }
 
 func authenticated(r chi.Router) {
-	authSvc := auth.New(redisClient)
+	// Only tokens issued for the audience of this deployment are accepted, if configured
+	authSvc := auth.New(store, auth.WithVerifier(verifier), auth.WithAudience(audience),
+		auth.WithTokenHashKey(hashKey), auth.WithVerifyCache(verifyCache))
 
 	// Middlewares
 	// Authentication middleware - Parses the header and validates the token
@@ -48,4 +116,7 @@ func authenticated(r chi.Router) {
 	a := NewAuthHandler(authSvc)
 	r.Post("/v1/verify", a.Verify())
 	r.Get("/v1/logout", a.Logout())
+	r.Get("/v1/sessions", a.Sessions())
+	r.Delete("/v1/sessions", a.RevokeOtherSessions())
+	r.Delete("/v1/sessions/{id}", a.RevokeSession())
 }
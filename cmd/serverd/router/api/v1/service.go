@@ -9,6 +9,11 @@ import (
 var _ AuthService = (*auth.Service)(nil)
 
 type AuthService interface {
-	Login(ctx context.Context, authCode string) (auth.Token, error)
-	Logout(ctx context.Context, userIDNo string) error
+	Login(ctx context.Context, req auth.LoginRequest) (auth.Token, error)
+	Refresh(ctx context.Context, refreshToken string) (auth.Token, error)
+	Logout(ctx context.Context, c auth.Claims) error
+	Revoke(ctx context.Context, tokenString string) error
+	Sessions(ctx context.Context, c auth.Claims) ([]auth.Session, error)
+	RevokeSession(ctx context.Context, subject, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, c auth.Claims) error
 }
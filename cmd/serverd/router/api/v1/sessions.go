@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/severedsea/golang-kit/web"
+	"github.com/severedsea/jwt-server/internal/service/auth"
+)
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// IdleExpiresAt is only set if the session has an idle timeout
+	IdleExpiresAt *time.Time `json:"idle_expires_at,omitempty"`
+	UserAgent     string     `json:"user_agent,omitempty"`
+	IP            string     `json:"ip,omitempty"`
+	Current       bool       `json:"current"`
+}
+
+// Sessions lists the active sessions of the subject of the access_token
+func (h AuthHandler) Sessions() http.HandlerFunc {
+	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+
+		claims, err := auth.ClaimsFromContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		sessions, err := h.auth.Sessions(ctx, claims)
+		if err != nil {
+			return err
+		}
+
+		result := make([]SessionResponse, 0, len(sessions))
+		for _, it := range sessions {
+			it := it
+			resp := SessionResponse{
+				ID:         it.ID,
+				CreatedAt:  it.CreatedAt,
+				LastSeenAt: it.LastSeenAt,
+				ExpiresAt:  it.ExpiresAt,
+				UserAgent:  it.UserAgent,
+				IP:         it.IP,
+				Current:    it.Current,
+			}
+			if !it.IdleExpiresAt.IsZero() {
+				resp.IdleExpiresAt = &it.IdleExpiresAt
+			}
+			result = append(result, resp)
+		}
+
+		web.RespondJSON(ctx, w, result, nil)
+
+		return nil
+	})
+}
+
+// RevokeSession logs out one session of the subject of the access_token
+func (h AuthHandler) RevokeSession() http.HandlerFunc {
+	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+
+		claims, err := auth.ClaimsFromContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		return h.auth.RevokeSession(ctx, claims.Subject, chi.URLParam(r, "id"))
+	})
+}
+
+// RevokeOtherSessions logs out every session of the subject of the access_token except its own
+func (h AuthHandler) RevokeOtherSessions() http.HandlerFunc {
+	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+
+		claims, err := auth.ClaimsFromContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		return h.auth.RevokeOtherSessions(ctx, claims)
+	})
+}
@@ -3,21 +3,35 @@ package router
 
 import (
 	"net/http"
+	"os"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/severedsea/jwt-server/cmd/serverd/router/api"
+	"github.com/severedsea/jwt-server/cmd/serverd/router/wellknown"
 )
 
 // Handler returns the http handler that handles all requests
-func Handler() http.Handler {
+// Routes that issue tokens are not mounted in verify-only mode.
+func Handler(verifyOnly bool) http.Handler {
 	r := chi.NewRouter()
 
 	// Top-level middlewares
 	r.Use(chimiddleware.Recoverer)
+	// The client IP recorded in the sessions is taken from X-Forwarded-For or X-Real-IP behind a trusted proxy
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		r.Use(chimiddleware.RealIP)
+	}
+
+	// Well-known routes
+	r.Group(wellknown.Router)
 
 	// API routes
-	r.Group(api.Router)
+	if verifyOnly {
+		r.Group(api.VerifyOnlyRouter)
+	} else {
+		r.Group(api.Router)
+	}
 
 	return r
 }
@@ -0,0 +1,33 @@
+// Package wellknown contains the /.well-known handlers
+package wellknown
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/severedsea/golang-kit/web"
+	"github.com/severedsea/golang-kit/web/middleware"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+)
+
+const (
+	// jwksMaxAge is how long clients may cache the key set
+	// Keys must be published for at least this long before they are used for signing
+	jwksMaxAge = 5 * time.Minute
+)
+
+// Router registers handlers to the router provided in the argument
+func Router(r chi.Router) {
+	r.With(middleware.MaxAge(int(jwksMaxAge.Seconds()))).
+		Get("/.well-known/jwks.json", JWKS())
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set
+func JWKS() http.HandlerFunc {
+	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+		web.RespondJSON(r.Context(), w, jwt.JWKS(), nil)
+
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/envvar"
+	"github.com/severedsea/golang-kit/projectpath"
+)
+
+// minSecretLength is the minimum length of SIGNER_SECRET, so that it cannot be guessed
+const minSecretLength = 32
+
+// listenConfig is where signerd listens and how the callers authenticate
+type listenConfig struct {
+	// Socket is the Unix socket that only the owner can connect to, it is used instead of Addr if set
+	Socket string
+	Addr   string
+	// Secret is the shared secret that every request must have as a bearer token, if set
+	Secret string
+	// TLSConfig is the TLS config of the TCP listener, which requires client certificates for mTLS if it has ClientCAs
+	TLSConfig *tls.Config
+}
+
+// listenConfigFromEnv returns where signerd listens, which is 127.0.0.1 unless configured otherwise
+//   - SIGNER_SOCKET: Unix socket to listen on instead of TCP
+//   - SIGNER_ADDR: TCP address to listen on, 127.0.0.1:PORT by default
+//   - SIGNER_SECRET: shared secret that every request must have as a bearer token
+//   - SIGNER_TLS_CERT_PATH and SIGNER_TLS_KEY_PATH: PEM server certificate and key
+//   - SIGNER_TLS_CLIENT_CA_PATH: PEM bundle of the CAs of the client certificates, which are then required
+//
+// Anyone who can reach signerd can sign tokens, so a non-loopback address requires the shared secret or mTLS.
+func listenConfigFromEnv() (listenConfig, error) {
+	cfg := listenConfig{
+		Socket: os.Getenv("SIGNER_SOCKET"),
+		Addr:   envvar.Get("SIGNER_ADDR", net.JoinHostPort("127.0.0.1", envvar.Get("PORT", "3001"))),
+		Secret: os.Getenv("SIGNER_SECRET"),
+	}
+	if cfg.Secret != "" && len(cfg.Secret) < minSecretLength {
+		return listenConfig{}, errors.Errorf("SIGNER_SECRET must be at least %d characters", minSecretLength)
+	}
+	if cfg.Socket != "" {
+		return cfg, nil
+	}
+
+	certPath, keyPath := os.Getenv("SIGNER_TLS_CERT_PATH"), os.Getenv("SIGNER_TLS_KEY_PATH")
+	clientCAPath := os.Getenv("SIGNER_TLS_CLIENT_CA_PATH")
+	if certPath != "" || keyPath != "" {
+		if certPath == "" || keyPath == "" {
+			return listenConfig{}, errors.New("SIGNER_TLS_CERT_PATH and SIGNER_TLS_KEY_PATH must be both set")
+		}
+
+		cert, err := tls.LoadX509KeyPair(projectpath.Abs(certPath), projectpath.Abs(keyPath))
+		if err != nil {
+			return listenConfig{}, errors.Wrap(err, "SIGNER_TLS_CERT_PATH")
+		}
+		cfg.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	}
+
+	if clientCAPath != "" {
+		if cfg.TLSConfig == nil {
+			return listenConfig{}, errors.New("SIGNER_TLS_CLIENT_CA_PATH requires SIGNER_TLS_CERT_PATH and SIGNER_TLS_KEY_PATH")
+		}
+
+		b, err := os.ReadFile(projectpath.Abs(clientCAPath))
+		if err != nil {
+			return listenConfig{}, errors.Wrap(err, "SIGNER_TLS_CLIENT_CA_PATH")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return listenConfig{}, errors.Errorf("SIGNER_TLS_CLIENT_CA_PATH has no PEM certificate: %s", clientCAPath)
+		}
+		cfg.TLSConfig.ClientCAs = pool
+		cfg.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if !isLoopback(cfg.Addr) && cfg.Secret == "" && clientCAPath == "" {
+		return listenConfig{}, errors.Errorf("SIGNER_ADDR %s is not a loopback address, SIGNER_SECRET or SIGNER_TLS_CLIENT_CA_PATH is required", cfg.Addr)
+	}
+
+	return cfg, nil
+}
+
+// isLoopback checks if the TCP address only accepts connections from the same host
+// An address without a host, e.g. :3001, listens on every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenConfigFromEnv(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+
+	testCases := []struct {
+		desc      string
+		socket    string
+		port      string
+		addr      string
+		secret    string
+		clientCA  string
+		expAddr   string
+		expectErr bool
+	}{
+		{desc: "default", expAddr: "127.0.0.1:3001"},
+		{desc: "port", port: "4001", expAddr: "127.0.0.1:4001"},
+		{desc: "localhost", addr: "localhost:3001", expAddr: "localhost:3001"},
+		{desc: "ipv6 loopback", addr: "[::1]:3001", expAddr: "[::1]:3001"},
+		{desc: "socket", socket: "/run/signerd.sock", addr: ":3001", expAddr: ":3001"},
+		{desc: "every interface with secret", addr: ":3001", secret: secret, expAddr: ":3001"},
+		{desc: "every interface without secret", addr: ":3001", expectErr: true},
+		{desc: "remote without secret", addr: "10.0.0.1:3001", expectErr: true},
+		{desc: "short secret", secret: "secret", expectErr: true},
+		{desc: "client CA without certificate", addr: ":3001", clientCA: "ca.pem", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Given:
+			t.Setenv("SIGNER_SOCKET", tc.socket)
+			t.Setenv("PORT", tc.port)
+			t.Setenv("SIGNER_ADDR", tc.addr)
+			t.Setenv("SIGNER_SECRET", tc.secret)
+			t.Setenv("SIGNER_TLS_CERT_PATH", "")
+			t.Setenv("SIGNER_TLS_KEY_PATH", "")
+			t.Setenv("SIGNER_TLS_CLIENT_CA_PATH", tc.clientCA)
+
+			// When:
+			actual, err := listenConfigFromEnv()
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			if tc.expectErr {
+				return
+			}
+			assert.Equal(t, tc.expAddr, actual.Addr)
+			assert.Equal(t, tc.socket, actual.Socket)
+			assert.Equal(t, tc.secret, actual.Secret)
+			assert.Nil(t, actual.TLSConfig)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Command signerd is the reference signing service of jwt.HTTPSigningBackend
+// It holds the private key so that serverd does not have to.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/envvar"
+	"github.com/severedsea/golang-kit/web/server"
+	"github.com/severedsea/jwt-server/cmd/signerd/router"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+)
+
+func main() {
+	// Validate envvars
+	envvarValidate()
+
+	// Keys
+	keySource, err := jwt.KeySourceFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "jwt"))
+	}
+	if _, ok := keySource.(jwt.RemoteKeySource); ok {
+		log.Fatalf("jwt: JWT_SIGNER_URL cannot be configured for signerd")
+	}
+	keys, err := jwt.LoadKeyStore(keySource)
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "jwt"))
+	}
+
+	// Reload keys on SIGHUP or on the optional polling interval
+	reloadInterval, _ := time.ParseDuration(os.Getenv("JWT_KEY_RELOAD_INTERVAL"))
+	go jwt.NewReloader(keys, keySource, reloadInterval).Run(context.Background())
+
+	// Listen on the Unix socket, or on localhost unless the callers authenticate
+	cfg, err := listenConfigFromEnv()
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "listen"))
+	}
+	handler := router.Handler(keys, cfg.Secret)
+
+	switch {
+	case cfg.Socket != "":
+		serveUnix(cfg.Socket, handler)
+	case cfg.TLSConfig != nil:
+		serveTLS(cfg.Addr, cfg.TLSConfig, handler)
+	default:
+		server.New(cfg.Addr, handler).Start()
+	}
+}
+
+// serveUnix serves on a Unix socket that only the owner can connect to
+func serveUnix(socket string, handler http.Handler) {
+	// Remove the socket left behind by a previous run
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("%s", errors.Wrap(err, "socket"))
+	}
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "socket"))
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		log.Fatalf("%s", errors.Wrap(err, "socket"))
+	}
+
+	log.Printf("Server started at socket %s", socket)
+	s := &http.Server{Handler: handler, ReadHeaderTimeout: 20 * time.Second}
+	if err := s.Serve(l); err != http.ErrServerClosed {
+		log.Fatalf("Serve: %s", err)
+	}
+}
+
+// serveTLS serves on the TCP address with TLS, which requires client certificates if the config has ClientCAs
+func serveTLS(addr string, cfg *tls.Config, handler http.Handler) {
+	log.Printf("Server started at %s with TLS", addr)
+	s := &http.Server{Addr: addr, Handler: handler, TLSConfig: cfg, ReadHeaderTimeout: 20 * time.Second}
+	if err := s.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+		log.Fatalf("ListenAndServeTLS: %s", err)
+	}
+}
+
+func envvarValidate() {
+	envvar.ValidateEitherNotEmptyF("JWT_KEY_DIR", "JWT_PRIVATE_KEY_PATH", "JWT_PRIVATE_KEY")
+	if envvar.ValidateNotEmpty("JWT_KEY_RELOAD_INTERVAL") {
+		envvar.ValidateDurationF("JWT_KEY_RELOAD_INTERVAL")
+	}
+}
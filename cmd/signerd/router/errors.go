@@ -0,0 +1,18 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/severedsea/golang-kit/web"
+)
+
+var (
+	// 4xx
+
+	// ErrUnauthorized is the error returned if the request does not have the shared secret
+	ErrUnauthorized = &web.Error{Status: http.StatusUnauthorized, Code: "unauthorized", Desc: "Missing or invalid shared secret"}
+	// ErrInvalidSignRequest is the error returned if the sign request is malformed
+	ErrInvalidSignRequest = &web.Error{Status: http.StatusBadRequest, Code: "invalid_sign_request", Desc: "Invalid sign request"}
+	// ErrKeyNotActive is the error returned if the requested key is not the active key, e.g. after a key rotation
+	ErrKeyNotActive = &web.Error{Status: http.StatusConflict, Code: "key_not_active", Desc: "Key is not the active key"}
+)
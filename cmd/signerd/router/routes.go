@@ -0,0 +1,43 @@
+// Package router contains routing configuration for signerd
+package router
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/severedsea/golang-kit/web"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+)
+
+// Handler returns the http handler that handles all requests
+// The routes are the protocol of jwt.HTTPSigningBackend. Every request must have the shared secret if it is not empty.
+func Handler(keys *jwt.KeyStore, secret string) http.Handler {
+	r := chi.NewRouter()
+
+	// Top-level middlewares
+	r.Use(chimiddleware.Recoverer)
+	if secret != "" {
+		r.Use(requireSecret(secret))
+	}
+
+	h := NewSignHandler(keys)
+	r.Get("/v1/keys", h.Keys())
+	r.Post("/v1/sign", h.Sign())
+
+	return r
+}
+
+// requireSecret rejects the requests that do not have the shared secret as a bearer token
+func requireSecret(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+			if !jwt.SignerSecretMatches(r, secret) {
+				return ErrUnauthorized
+			}
+			next.ServeHTTP(w, r)
+
+			return nil
+		})
+	}
+}
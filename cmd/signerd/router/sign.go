@@ -0,0 +1,59 @@
+package router
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/severedsea/golang-kit/web"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+)
+
+type SignHandler struct {
+	keys *jwt.KeyStore
+}
+
+func NewSignHandler(keys *jwt.KeyStore) SignHandler {
+	return SignHandler{
+		keys: keys,
+	}
+}
+
+// Keys returns the public keys as a JSON Web Key Set, starting with the active key
+func (h SignHandler) Keys() http.HandlerFunc {
+	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+		web.RespondJSON(r.Context(), w, h.keys.KeyRing().JWKS(), nil)
+
+		return nil
+	})
+}
+
+// Sign returns the JWS signature of the signing input with the active key
+func (h SignHandler) Sign() http.HandlerFunc {
+	return web.WrapHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+
+		var req jwt.SignRequest
+		if _, err := web.ParseJSONBody(&req, r.Body); err != nil {
+			return err
+		}
+		signingInput, err := base64.RawURLEncoding.DecodeString(req.SigningInput)
+		if err != nil || len(signingInput) == 0 {
+			return ErrInvalidSignRequest
+		}
+
+		// The kid is already in the signed header, so only the active key may sign
+		key := h.keys.KeyRing().SigningKey()
+		if req.KeyID != key.ID {
+			return ErrKeyNotActive
+		}
+
+		sig, err := key.Sign(ctx, signingInput)
+		if err != nil {
+			return web.NewError(jwt.ErrJWT, err.Error())
+		}
+
+		web.RespondJSON(ctx, w, jwt.SignResponse{Signature: base64.RawURLEncoding.EncodeToString(sig)}, nil)
+
+		return nil
+	})
+}
@@ -0,0 +1,131 @@
+package router
+
+import (
+	"context"
+
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignHandler(t *testing.T) {
+	t.Parallel()
+
+	// Given: signerd holding the private key
+	key, err := jwt.GenerateKey("ES256")
+	require.NoError(t, err)
+	ring, err := jwt.NewKeyRing(key)
+	require.NoError(t, err)
+	srv := httptest.NewServer(Handler(jwt.NewKeyStore(ring), ""))
+	defer srv.Close()
+
+	// Given: serverd using signerd as the signing backend
+	s, err := jwt.NewRemoteKeySource(srv.URL)
+	require.NoError(t, err)
+	keys, err := jwt.LoadKeyStore(s)
+	require.NoError(t, err)
+	signer, err := jwt.NewSigner(keys)
+	require.NoError(t, err)
+
+	// When:
+	given := jwtgo.RegisteredClaims{Subject: "subject", Issuer: jwt.Issuer}
+	tokenString, err := signer.Sign(context.Background(), given)
+
+	// Then: token is verified with the public key only
+	require.NoError(t, err)
+	verifier, err := jwt.NewVerifier(keys)
+	require.NoError(t, err)
+	actual := jwtgo.RegisteredClaims{}
+	assert.NoError(t, verifier.Parse(tokenString, &actual))
+	assert.Equal(t, given, actual)
+}
+
+func TestSignHandler_Error(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwt.GenerateKey("")
+	require.NoError(t, err)
+	ring, err := jwt.NewKeyRing(key)
+	require.NoError(t, err)
+	srv := httptest.NewServer(Handler(jwt.NewKeyStore(ring), ""))
+	t.Cleanup(srv.Close)
+
+	testCases := []struct {
+		desc     string
+		given    string
+		expected int
+	}{
+		{desc: "key not active", given: `{"kid":"retired","signing_input":"YQ"}`, expected: http.StatusConflict},
+		{desc: "invalid signing input", given: `{"kid":"` + key.ID + `","signing_input":"!"}`, expected: http.StatusBadRequest},
+		{desc: "empty signing input", given: `{"kid":"` + key.ID + `"}`, expected: http.StatusBadRequest},
+		{desc: "invalid JSON", given: `{`, expected: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			resp, err := srv.Client().Post(srv.URL+"/v1/sign", "application/json", bytes.NewBufferString(tc.given))
+
+			// Then:
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tc.expected, resp.StatusCode)
+
+			var actual map[string]string
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&actual))
+			assert.NotEmpty(t, actual["code"])
+		})
+	}
+}
+
+func TestSignHandler_Secret(t *testing.T) {
+	t.Parallel()
+
+	// Given: signerd requiring a shared secret
+	key, err := jwt.GenerateKey("")
+	require.NoError(t, err)
+	ring, err := jwt.NewKeyRing(key)
+	require.NoError(t, err)
+	secret := "0123456789abcdef0123456789abcdef"
+	srv := httptest.NewServer(Handler(jwt.NewKeyStore(ring), secret))
+	t.Cleanup(srv.Close)
+
+	testCases := []struct {
+		desc      string
+		given     string
+		expectErr bool
+	}{
+		{desc: "secret", given: secret},
+		{desc: "wrong secret", given: "fedcba9876543210fedcba9876543210", expectErr: true},
+		{desc: "no secret", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			var opts []jwt.RemoteOption
+			if tc.given != "" {
+				opts = append(opts, jwt.WithSignerSecret(tc.given))
+			}
+			s, err := jwt.NewRemoteKeySource(srv.URL, opts...)
+			require.NoError(t, err)
+			_, err = jwt.LoadKeyStore(s)
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+		})
+	}
+}
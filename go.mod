@@ -9,6 +9,7 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/severedsea/golang-kit v0.0.0-20230823154911-979071a2cf82
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/crypto v0.12.0
 	golang.org/x/exp v0.0.0-20230817173708-d852ddb80c63
 )
 
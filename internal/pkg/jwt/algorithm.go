@@ -0,0 +1,88 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// signingMethods are the supported signing algorithms
+var signingMethods = map[string]jwt.SigningMethod{
+	jwt.SigningMethodRS256.Alg(): jwt.SigningMethodRS256,
+	jwt.SigningMethodES256.Alg(): jwt.SigningMethodES256,
+	jwt.SigningMethodES384.Alg(): jwt.SigningMethodES384,
+	jwt.SigningMethodEdDSA.Alg(): jwt.SigningMethodEdDSA,
+}
+
+// signingMethod returns the signing method for the algorithm after checking that the key type matches it
+// If alg is empty, the default algorithm for the key type is used
+func signingMethod(alg string, pubKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	expected, err := defaultAlgorithm(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if alg == "" {
+		alg = expected
+	}
+
+	method, ok := signingMethods[alg]
+	if !ok {
+		return nil, errors.Errorf("unsupported signing algorithm %s", alg)
+	}
+	if alg != expected {
+		return nil, errors.Errorf("%T cannot be used with signing algorithm %s", pubKey, alg)
+	}
+
+	return method, nil
+}
+
+// defaultAlgorithm returns the only supported signing algorithm for the public key type
+func defaultAlgorithm(pubKey crypto.PublicKey) (string, error) {
+	switch k := pubKey.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256.Alg(), nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256.Alg(), nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384.Alg(), nil
+		}
+
+		return "", errors.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA.Alg(), nil
+	}
+
+	return "", errors.Errorf("unsupported public key type %T", pubKey)
+}
+
+// GenerateKey generates a new key pair for the algorithm, which defaults to RS256 if empty
+func GenerateKey(alg string) (Key, error) {
+	var privKey crypto.Signer
+	var err error
+	switch alg {
+	case "", jwt.SigningMethodRS256.Alg():
+		privKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		privKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodES384.Alg():
+		privKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, privKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return Key{}, errors.Errorf("unsupported signing algorithm %s", alg)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	return NewKey(alg, privKey, privKey.Public())
+}
@@ -0,0 +1,160 @@
+package jwt
+
+import (
+	"context"
+
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKey_Algorithm(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		keyAlg   string
+		alg      string
+		expected string
+	}{
+		{keyAlg: "RS256", alg: "", expected: "RS256"},
+		{keyAlg: "RS256", alg: "RS256", expected: "RS256"},
+		{keyAlg: "ES256", alg: "", expected: "ES256"},
+		{keyAlg: "ES256", alg: "ES256", expected: "ES256"},
+		{keyAlg: "ES384", alg: "", expected: "ES384"},
+		{keyAlg: "EdDSA", alg: "EdDSA", expected: "EdDSA"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.keyAlg+"/"+tc.alg, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			given := newTestKeyWithAlg(t, tc.keyAlg)
+
+			// When:
+			actual, err := NewKey(tc.alg, given.PrivateKey, given.PublicKey)
+
+			// Then:
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.Method.Alg())
+			assert.Equal(t, given.ID, actual.ID)
+		})
+	}
+}
+
+func TestNewKey_Algorithm_Error(t *testing.T) {
+	t.Parallel()
+
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc string
+		key  Key
+		alg  string
+	}{
+		{desc: "RSA key with ES256", key: newTestKey(t), alg: "ES256"},
+		{desc: "P-256 key with ES384", key: newTestKeyWithAlg(t, "ES256"), alg: "ES384"},
+		{desc: "Ed25519 key with RS256", key: newTestKeyWithAlg(t, "EdDSA"), alg: "RS256"},
+		{desc: "unsupported algorithm", key: newTestKey(t), alg: "HS256"},
+		{desc: "unsupported curve", key: Key{PrivateKey: p521, PublicKey: p521.Public()}, alg: ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			_, err := NewKey(tc.alg, tc.key.PrivateKey, tc.key.PublicKey)
+
+			// Then:
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSignParse_Algorithms(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range []string{"RS256", "ES256", "ES384", "EdDSA"} {
+		alg := alg
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ring, err := NewKeyRing(newTestKeyWithAlg(t, alg))
+			require.NoError(t, err)
+			given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer}
+
+			// When:
+			tokenString, err := sign(context.Background(), ring, given)
+			require.NoError(t, err)
+			actual := jwt.RegisteredClaims{}
+			err = parse(ring, tokenString, &actual)
+
+			// Then:
+			assert.NoError(t, err)
+			assert.Equal(t, given, actual)
+
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &jwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, alg, token.Header["alg"])
+		})
+	}
+}
+
+func TestParse_AlgorithmPinned(t *testing.T) {
+	t.Parallel()
+
+	// Given: key ring pinned to RS256
+	ring := newTestKeyRing(t)
+	key := ring.SigningKey()
+
+	testCases := []struct {
+		desc   string
+		method jwt.SigningMethod
+		key    interface{}
+	}{
+		{
+			desc:   "RS384 with the same RSA key",
+			method: jwt.SigningMethodRS384,
+			key:    key.PrivateKey,
+		},
+		{
+			desc:   "PS256 with the same RSA key",
+			method: jwt.SigningMethodPS256,
+			key:    key.PrivateKey,
+		},
+		{
+			desc:   "ES256 with another key",
+			method: jwt.SigningMethodES256,
+			key:    newTestKeyWithAlg(t, "ES256").PrivateKey,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			token := jwt.NewWithClaims(tc.method, jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer})
+			token.Header["kid"] = key.ID
+			tokenString, err := token.SignedString(tc.key)
+			require.NoError(t, err)
+
+			// When:
+			err = parse(ring, tokenString, &jwt.RegisteredClaims{})
+
+			// Then:
+			assert.Equal(t, ErrInvalidToken, err)
+		})
+	}
+}
@@ -13,14 +13,21 @@ const (
 )
 
 // NewRegisteredClaims returns a new standard claims with the basic claims populated
-func NewRegisteredClaims(subject string, tokenExpiryDuration time.Duration) jwt.RegisteredClaims {
+// The audience claim is only set if audiences are provided.
+func NewRegisteredClaims(subject string, tokenExpiryDuration time.Duration, audience ...string) jwt.RegisteredClaims {
 	now := timex.NowSGT()
 	expiresAt := now.Add(tokenExpiryDuration)
 
-	return jwt.RegisteredClaims{
+	c := jwt.RegisteredClaims{
 		ExpiresAt: jwt.NewNumericDate(expiresAt),
 		Issuer:    Issuer,
 		Subject:   subject,
 		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
 	}
+	if len(audience) > 0 {
+		c.Audience = audience
+	}
+
+	return c
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,7 +23,17 @@ func TestNewRegisteredClaims(t *testing.T) {
 	assert.True(t, actual.IssuedAt.Unix() > 0, "should be populated")
 	assert.True(t, actual.IssuedAt.Unix() > time.Time{}.Unix(), "should not be zero time")
 	assert.True(t, actual.IssuedAt.Unix() >= afterExecTime.Unix())
+	assert.Equal(t, actual.IssuedAt, actual.NotBefore)
 	assert.True(t, actual.ExpiresAt.Unix() > 0, "should be populated")
 	assert.True(t, actual.ExpiresAt.Unix() > time.Time{}.Unix(), "should not be zero time")
 	assert.True(t, actual.ExpiresAt.Unix() <= afterExecTime.Add(duration).Unix())
 }
+
+func TestNewRegisteredClaims_Audience(t *testing.T) {
+	// When:
+	actual := NewRegisteredClaims("christina_ang", time.Hour, "orders")
+
+	// Then:
+	assert.Equal(t, jwt.ClaimStrings{"orders"}, actual.Audience)
+	assert.Empty(t, NewRegisteredClaims("christina_ang", time.Hour).Audience)
+}
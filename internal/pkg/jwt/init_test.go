@@ -1,9 +1,24 @@
 package jwt
 
 import (
-	"os"
+	"crypto/rand"
+	"crypto/rsa"
 )
 
 func init() {
-	InitKeyFiles(os.Getenv("JWT_PRIVATE_KEY_PATH"), os.Getenv("JWT_PUBLIC_KEY_PATH"))
+	// Initialise the default key store used by the package-level functions
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	key, err := NewKey("", privKey, privKey.Public())
+	if err != nil {
+		panic(err)
+	}
+	k, err := NewKeyRing(key)
+	if err != nil {
+		panic(err)
+	}
+
+	defaultKeyStore.Store(k)
 }
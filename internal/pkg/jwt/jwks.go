@@ -0,0 +1,167 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	keyTypeRSA = "RSA"
+	keyTypeEC  = "EC"
+	keyTypeOKP = "OKP"
+)
+
+// JSONWebKey is the RFC 7517 representation of a public verification key
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	// Curve is only used by EC and OKP keys
+	Curve string `json:"crv,omitempty"`
+	// RSA modulus and exponent
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP coordinates
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the RFC 7517 JSON Web Key Set
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set
+func JWKS() JSONWebKeySet {
+	return defaultKeyStore.KeyRing().JWKS()
+}
+
+// JWKS returns the public keys of the active and retired keys as a JSON Web Key Set
+func (k *KeyRing) JWKS() JSONWebKeySet {
+	keys := k.Keys()
+
+	result := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(keys))}
+	for _, it := range keys {
+		// Keys in the ring have already been validated by NewKey
+		jwk, _ := newJSONWebKey(it.PublicKey)
+		jwk.KeyID = it.ID
+		jwk.Algorithm = it.Method.Alg()
+		jwk.Use = "sig"
+
+		result.Keys = append(result.Keys, jwk)
+	}
+
+	return result
+}
+
+// newJSONWebKey returns the key type specific members of the public key
+func newJSONWebKey(pubKey crypto.PublicKey) (JSONWebKey, error) {
+	switch k := pubKey.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			KeyType: keyTypeRSA,
+			N:       encodeBase64URL(k.N.Bytes()),
+			E:       encodeBase64URL(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		// Coordinates are padded to the full size of the curve as required by RFC 7518
+		size := (k.Curve.Params().BitSize + 7) / 8
+
+		return JSONWebKey{
+			KeyType: keyTypeEC,
+			Curve:   k.Curve.Params().Name,
+			X:       encodeBase64URL(k.X.FillBytes(make([]byte, size))),
+			Y:       encodeBase64URL(k.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return JSONWebKey{
+			KeyType: keyTypeOKP,
+			Curve:   "Ed25519",
+			X:       encodeBase64URL(k),
+		}, nil
+	}
+
+	return JSONWebKey{}, errors.Errorf("unsupported public key type %T", pubKey)
+}
+
+// PublicKey returns the public key represented by the JSON Web Key
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case keyTypeRSA:
+		n, err := decodeBase64URLInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "n")
+		}
+		e, err := decodeBase64URLInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "e")
+		}
+		if !e.IsInt64() || e.Int64() < 2 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case keyTypeEC:
+		var curve elliptic.Curve
+		switch k.Curve {
+		case elliptic.P256().Params().Name:
+			curve = elliptic.P256()
+		case elliptic.P384().Params().Name:
+			curve = elliptic.P384()
+		default:
+			return nil, errors.Errorf("unsupported elliptic curve %s", k.Curve)
+		}
+
+		x, err := decodeBase64URLInt(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "x")
+		}
+		y, err := decodeBase64URLInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "y")
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case keyTypeOKP:
+		if k.Curve != "Ed25519" {
+			return nil, errors.Errorf("unsupported curve %s", k.Curve)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "x")
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size")
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.Errorf("unsupported key type %s", k.KeyType)
+}
+
+func decodeBase64URLInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	jwksTimeout = 10 * time.Second
+	// jwksMaxSize caps the response size, which is a few KB for a handful of keys
+	jwksMaxSize = 1 << 20
+)
+
+// JWKSKeySource loads verification-only keys from the JSON Web Key Set published by the issuing replicas
+// Keys that are not signing keys are ignored.
+type JWKSKeySource struct {
+	URL string
+	// Client defaults to an HTTP client with a 10s timeout
+	Client *http.Client
+}
+
+// Load implements KeySource
+func (s JWKSKeySource) Load() (*KeyRing, error) {
+	v, err := fetchJWKS(context.Background(), s.Client, s.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwks")
+	}
+
+	keys, err := newKeysFromJWKS(v)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := NewVerificationKeyRing(keys...)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwks")
+	}
+
+	return k, nil
+}
+
+// newKeysFromJWKS returns the signing keys of the JSON Web Key Set in the same order
+func newKeysFromJWKS(v JSONWebKeySet) ([]Key, error) {
+	keys := make([]Key, 0, len(v.Keys))
+	for _, it := range v.Keys {
+		if it.Use != "" && it.Use != "sig" {
+			continue
+		}
+
+		pubKey, err := it.PublicKey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "jwks key %s", it.KeyID)
+		}
+		key, err := NewKey(it.Algorithm, nil, pubKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "jwks key %s", it.KeyID)
+		}
+		// Tokens reference the published key ID
+		if it.KeyID != "" {
+			key.ID = it.KeyID
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func fetchJWKS(ctx context.Context, client *http.Client, url string) (JSONWebKeySet, error) {
+	if client == nil {
+		client = &http.Client{Timeout: jwksTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return JSONWebKeySet{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return JSONWebKeySet{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return JSONWebKeySet{}, errors.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var v JSONWebKeySet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, jwksMaxSize)).Decode(&v); err != nil {
+		return JSONWebKeySet{}, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"context"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/severedsea/golang-kit/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWKSKeySource_Load(t *testing.T) {
+	t.Parallel()
+
+	// Given: issuing replica with an active and a retired key
+	retired := newTestKeyWithAlg(t, "ES256")
+	retired.PrivateKey = nil
+	issuer, err := NewKeyRing(newTestKey(t), retired)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		web.RespondJSON(r.Context(), w, issuer.JWKS(), nil)
+	}))
+	defer srv.Close()
+
+	// When:
+	actual, err := JWKSKeySource{URL: srv.URL, Client: srv.Client()}.Load()
+
+	// Then:
+	require.NoError(t, err)
+	assert.False(t, actual.CanSign())
+	assert.Equal(t, keyIDs(issuer), keyIDs(actual))
+
+	// Then: tokens signed by the issuing replica are verified
+	given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer}
+	tokenString, err := sign(context.Background(), issuer, given)
+	require.NoError(t, err)
+	claims := jwt.RegisteredClaims{}
+	assert.NoError(t, parse(actual, tokenString, &claims))
+	assert.Equal(t, given, claims)
+}
+
+func TestJWKSKeySource_Load_Error(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc    string
+		status  int
+		payload string
+	}{
+		{desc: "error status", status: http.StatusInternalServerError, payload: `{"keys":[]}`},
+		{desc: "invalid JSON", status: http.StatusOK, payload: `{"keys":`},
+		{desc: "no keys", status: http.StatusOK, payload: `{"keys":[]}`},
+		{desc: "invalid key", status: http.StatusOK, payload: `{"keys":[{"kty":"RSA","kid":"kid","n":"!","e":"AQAB"}]}`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.payload))
+			}))
+			defer srv.Close()
+
+			// When:
+			actual, err := JWKSKeySource{URL: srv.URL, Client: srv.Client()}.Load()
+
+			// Then:
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
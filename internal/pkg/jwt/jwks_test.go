@@ -0,0 +1,162 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRing_JWKS(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	active := newTestKey(t)
+	retired := newTestKey(t)
+	retired.PrivateKey = nil
+	ring, err := NewKeyRing(active, retired)
+	require.NoError(t, err)
+
+	// When:
+	actual := ring.JWKS()
+
+	// Then:
+	require.Len(t, actual.Keys, 2)
+	for i, it := range []Key{active, retired} {
+		jwk := actual.Keys[i]
+		assert.Equal(t, "RSA", jwk.KeyType)
+		assert.Equal(t, it.ID, jwk.KeyID)
+		assert.Equal(t, "RS256", jwk.Algorithm)
+		assert.Equal(t, "sig", jwk.Use)
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		require.NoError(t, err)
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		require.NoError(t, err)
+		pubKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		assert.True(t, it.PublicKey.(*rsa.PublicKey).Equal(pubKey))
+		kid, err := KeyID(pubKey)
+		require.NoError(t, err)
+		assert.Equal(t, it.ID, kid)
+	}
+}
+
+func TestKeyRing_JWKS_JSON(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ring := newTestKeyRing(t)
+
+	// When:
+	b, err := json.Marshal(ring.JWKS())
+
+	// Then:
+	require.NoError(t, err)
+	var actual map[string][]map[string]string
+	require.NoError(t, json.Unmarshal(b, &actual))
+	require.Len(t, actual["keys"], 1)
+	assert.Equal(t, "AQAB", actual["keys"][0]["e"])
+	for _, it := range []string{"kty", "kid", "alg", "use", "n", "e"} {
+		assert.NotEmpty(t, actual["keys"][0][it], it)
+	}
+}
+
+func TestKeyRing_JWKS_KeyTypes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		alg      string
+		expected JSONWebKey
+	}{
+		{alg: "ES256", expected: JSONWebKey{KeyType: "EC", Curve: "P-256"}},
+		{alg: "ES384", expected: JSONWebKey{KeyType: "EC", Curve: "P-384"}},
+		{alg: "EdDSA", expected: JSONWebKey{KeyType: "OKP", Curve: "Ed25519"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.alg, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			key := newTestKeyWithAlg(t, tc.alg)
+			ring, err := NewKeyRing(key)
+			require.NoError(t, err)
+
+			// When:
+			actual := ring.JWKS()
+
+			// Then:
+			require.Len(t, actual.Keys, 1)
+			jwk := actual.Keys[0]
+			assert.Equal(t, tc.expected.KeyType, jwk.KeyType)
+			assert.Equal(t, tc.expected.Curve, jwk.Curve)
+			assert.Equal(t, key.ID, jwk.KeyID)
+			assert.Equal(t, tc.alg, jwk.Algorithm)
+			assert.NotEmpty(t, jwk.X)
+			assert.Empty(t, jwk.N)
+			assert.Empty(t, jwk.E)
+			if tc.expected.KeyType == "EC" {
+				assert.NotEmpty(t, jwk.Y)
+			}
+		})
+	}
+}
+
+func TestJSONWebKey_PublicKey(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range []string{"RS256", "ES256", "ES384", "EdDSA"} {
+		alg := alg
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			key := newTestKeyWithAlg(t, alg)
+			ring, err := NewKeyRing(key)
+			require.NoError(t, err)
+			given := ring.JWKS().Keys[0]
+
+			// When:
+			actual, err := given.PublicKey()
+
+			// Then:
+			require.NoError(t, err)
+			assert.Equal(t, key.PublicKey, actual)
+		})
+	}
+}
+
+func TestJSONWebKey_PublicKey_Error(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc  string
+		given JSONWebKey
+	}{
+		{desc: "unsupported key type", given: JSONWebKey{KeyType: "oct"}},
+		{desc: "invalid RSA modulus", given: JSONWebKey{KeyType: "RSA", N: "!", E: "AQAB"}},
+		{desc: "invalid RSA exponent", given: JSONWebKey{KeyType: "RSA", N: "AQAB", E: "AQ"}},
+		{desc: "unsupported curve", given: JSONWebKey{KeyType: "EC", Curve: "P-521", X: "AQAB", Y: "AQAB"}},
+		{desc: "point not on the curve", given: JSONWebKey{KeyType: "EC", Curve: "P-256", X: "AQAB", Y: "AQAB"}},
+		{desc: "invalid Ed25519 key", given: JSONWebKey{KeyType: "OKP", Curve: "Ed25519", X: "AQAB"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			actual, err := tc.given.PublicKey()
+
+			// Then:
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
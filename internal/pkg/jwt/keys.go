@@ -1,43 +1,216 @@
 package jwt
 
 import (
-	"crypto/rsa"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"log"
-	"os"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/pkg/errors"
-	"github.com/severedsea/golang-kit/projectpath"
 )
 
-var (
-	verifyKey *rsa.PublicKey
-	signKey   *rsa.PrivateKey
-)
+// Key is a key pair identified by its key ID
+type Key struct {
+	// ID is the key ID stamped into the `kid` header of tokens signed by this key
+	ID string
+	// Method is the signing algorithm this key is pinned to
+	Method jwt.SigningMethod
+	// PrivateKey is only required for the active signing key, unless it is held by the signing backend
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	// Backend signs with a private key held outside serverd
+	Backend SigningBackend
+}
 
-// InitKeys initialised the auth keys
-func InitKeys(privKeyBytes []byte, pubKeyBytes []byte) {
-	var err error
-	signKey, err = jwt.ParseRSAPrivateKeyFromPEM(privKeyBytes)
+// Sign returns the JWS signature of the signing input
+func (k Key) Sign(ctx context.Context, signingInput []byte) ([]byte, error) {
+	if k.Backend != nil {
+		return k.Backend.Sign(ctx, k.ID, signingInput)
+	}
+	if k.PrivateKey == nil {
+		return nil, errors.Errorf("key %s has no private key", k.ID)
+	}
+
+	sig, err := k.Method.Sign(string(signingInput), k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.RawURLEncoding.DecodeString(sig)
+}
+
+func (k Key) canSign() bool {
+	return k.PrivateKey != nil || k.Backend != nil
+}
+
+// NewKey returns a Key pinned to the provided algorithm with the ID derived from the public key
+// If alg is empty, the default algorithm for the key type is used.
+// Weak keys and mismatched key pairs are rejected.
+func NewKey(alg string, privKey crypto.Signer, pubKey crypto.PublicKey) (Key, error) {
+	method, err := signingMethod(alg, pubKey)
 	if err != nil {
-		log.Fatalf("%s", errors.Wrap(err, "private key"))
+		return Key{}, err
+	}
+
+	if err := checkKeyStrength(pubKey); err != nil {
+		return Key{}, err
+	}
+	if privKey != nil {
+		if err := checkKeyPair(privKey, pubKey); err != nil {
+			return Key{}, err
+		}
 	}
-	verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pubKeyBytes)
+
+	kid, err := KeyID(pubKey)
 	if err != nil {
-		log.Fatalf("%s", errors.Wrap(err, "public key"))
+		return Key{}, err
 	}
+
+	return Key{
+		ID:         kid,
+		Method:     method,
+		PrivateKey: privKey,
+		PublicKey:  pubKey,
+	}, nil
 }
 
-// InitKeyFiles initialises the auth keys from provided file paths
-func InitKeyFiles(privKeyPath string, pubKeyPath string) {
-	privKeyBytes, err := os.ReadFile(projectpath.Abs(privKeyPath))
+// KeyRing holds one active signing key and the retired keys that are still accepted for verification
+// Keys that are published ahead of their activation are also verification-only keys.
+type KeyRing struct {
+	active  Key
+	retired []Key
+	verify  map[string]Key
+}
+
+// NewKeyRing creates a key ring that signs with the active key and verifies with both the active and retired keys
+// The active key must pass a sign and verify self-test.
+func NewKeyRing(active Key, retired ...Key) (*KeyRing, error) {
+	if !active.canSign() || active.PublicKey == nil {
+		return nil, errors.New("active key requires both private and public key")
+	}
+	if active.Method == nil {
+		return nil, errors.New("active key has no signing method")
+	}
+	if err := selfTest(active); err != nil {
+		return nil, errors.Wrap(err, "active key")
+	}
+
+	return newKeyRing(active, retired)
+}
+
+// NewVerificationKeyRing creates a key ring without an active key, which can only verify tokens
+func NewVerificationKeyRing(keys ...Key) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one verification key is required")
+	}
+
+	return newKeyRing(Key{}, keys)
+}
+
+func newKeyRing(active Key, retired []Key) (*KeyRing, error) {
+	k := &KeyRing{
+		active:  active,
+		retired: retired,
+		verify:  map[string]Key{},
+	}
+	if active.canSign() {
+		k.verify[active.ID] = active
+	}
+	for _, it := range retired {
+		if it.PublicKey == nil || it.Method == nil {
+			return nil, errors.Errorf("retired key %s has no public key or signing method", it.ID)
+		}
+		if _, ok := k.verify[it.ID]; ok {
+			return nil, errors.Errorf("duplicate key id %s", it.ID)
+		}
+		k.verify[it.ID] = it
+	}
+
+	return k, nil
+}
+
+// CanSign returns true if the key ring has an active signing key
+func (k *KeyRing) CanSign() bool {
+	return k.active.canSign()
+}
+
+// SigningKey returns the active signing key, which is empty if the key ring is verification-only
+func (k *KeyRing) SigningKey() Key {
+	return k.active
+}
+
+// VerificationKey returns the key for the provided key ID
+func (k *KeyRing) VerificationKey(kid string) (Key, bool) {
+	key, ok := k.verify[kid]
+
+	return key, ok
+}
+
+// Keys returns all the keys in the ring, starting with the active key
+func (k *KeyRing) Keys() []Key {
+	if !k.CanSign() {
+		return append([]Key{}, k.retired...)
+	}
+
+	return append([]Key{k.active}, k.retired...)
+}
+
+// KeyID returns the RFC 7638 JWK thumbprint of the public key, which is used as the `kid`
+func KeyID(pubKey crypto.PublicKey) (string, error) {
+	jwk, err := newJSONWebKey(pubKey)
 	if err != nil {
-		log.Fatalf("%s", errors.Wrap(err, "private key"))
+		return "", err
 	}
-	pubKeyBytes, err := os.ReadFile(projectpath.Abs(pubKeyPath))
+
+	// Only the required members are part of the thumbprint
+	members := map[string]string{"kty": jwk.KeyType}
+	switch jwk.KeyType {
+	case keyTypeRSA:
+		members["n"], members["e"] = jwk.N, jwk.E
+	case keyTypeEC:
+		members["crv"], members["x"], members["y"] = jwk.Curve, jwk.X, jwk.Y
+	case keyTypeOKP:
+		members["crv"], members["x"] = jwk.Curve, jwk.X
+	}
+
+	// Map keys are marshalled in lexicographic order and without whitespace as required by RFC 7638
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+
+	return encodeBase64URL(sum[:]), nil
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// InitKeys initialised the auth keys
+// The algorithm defaults to the one matching the private key type if empty.
+// Retired public keys are only used to verify tokens signed before the last key rotation.
+func InitKeys(alg string, privKeyBytes []byte, pubKeyBytes []byte, retiredPubKeyBytes ...[]byte) {
+	k, err := newKeyRingFromPEM(alg, nil, privKeyBytes, pubKeyBytes, retiredPubKeyBytes...)
 	if err != nil {
-		log.Fatalf("%s", errors.Wrap(err, "public key"))
+		log.Fatalf("%s", err)
 	}
 
-	InitKeys(privKeyBytes, pubKeyBytes)
+	defaultKeyStore.Store(k)
+}
+
+// InitKeyFiles initialises the auth keys from provided file paths
+func InitKeyFiles(alg string, privKeyPath string, pubKeyPath string, retiredPubKeyPaths ...string) {
+	err := InitKeySource(FileKeySource{
+		Algorithm:             alg,
+		PrivateKeyPath:        privKeyPath,
+		PublicKeyPath:         pubKeyPath,
+		RetiredPublicKeyPaths: retiredPubKeyPaths,
+	})
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
 }
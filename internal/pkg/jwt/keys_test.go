@@ -0,0 +1,266 @@
+package jwt
+
+import (
+	"context"
+
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyRing(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	active := newTestKey(t)
+	retired := newTestKey(t)
+	retired.PrivateKey = nil
+
+	// When:
+	actual, err := NewKeyRing(active, retired)
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, active, actual.SigningKey())
+	assert.Equal(t, []Key{active, retired}, actual.Keys())
+
+	key, ok := actual.VerificationKey(active.ID)
+	assert.True(t, ok)
+	assert.Equal(t, active, key)
+
+	key, ok = actual.VerificationKey(retired.ID)
+	assert.True(t, ok)
+	assert.Equal(t, retired, key)
+
+	_, ok = actual.VerificationKey("unknown")
+	assert.False(t, ok)
+}
+
+func TestNewKeyRing_Error(t *testing.T) {
+	t.Parallel()
+
+	active := newTestKey(t)
+
+	testCases := []struct {
+		desc    string
+		active  Key
+		retired []Key
+	}{
+		{
+			desc:   "active key without private key",
+			active: Key{ID: active.ID, Method: active.Method, PublicKey: active.PublicKey},
+		},
+		{
+			desc:    "retired key without public key",
+			active:  active,
+			retired: []Key{{ID: "retired"}},
+		},
+		{
+			desc:   "active key fails the self-test",
+			active: Key{ID: active.ID, Method: active.Method, PrivateKey: active.PrivateKey, PublicKey: newTestKey(t).PublicKey},
+		},
+		{
+			desc:    "duplicate key id",
+			active:  active,
+			retired: []Key{active},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			actual, err := NewKeyRing(tc.active, tc.retired...)
+
+			// Then:
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
+
+func TestKeyID(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range []string{"RS256", "ES256", "ES384", "EdDSA"} {
+		alg := alg
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			key := newTestKeyWithAlg(t, alg)
+
+			// When:
+			actual, err := KeyID(key.PublicKey)
+
+			// Then:
+			require.NoError(t, err)
+			assert.Len(t, actual, 43, "base64url encoded SHA-256")
+			assert.Equal(t, key.ID, actual, "should be deterministic")
+			assert.NotEqual(t, actual, newTestKeyWithAlg(t, alg).ID)
+		})
+	}
+}
+
+func TestKeyID_RFC7638(t *testing.T) {
+	t.Parallel()
+
+	// Given: example key from RFC 7638 section 3.1
+	given := &rsa.PublicKey{E: 65537}
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	require.NoError(t, err)
+	given.N = new(big.Int).SetBytes(n)
+
+	// When:
+	actual, err := KeyID(given)
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", actual)
+}
+
+func TestSign_KeyID(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ring := newTestKeyRing(t)
+
+	// When:
+	actual, err := sign(context.Background(), ring, jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer})
+
+	// Then:
+	require.NoError(t, err)
+	token, _, err := jwt.NewParser().ParseUnverified(actual, &jwt.RegisteredClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, ring.SigningKey().ID, token.Header["kid"])
+}
+
+func TestParse_Rotation(t *testing.T) {
+	t.Parallel()
+
+	// Given: token signed by the previous key
+	previous := newTestKeyRing(t)
+	given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer}
+	tokenString, err := sign(context.Background(), previous, given)
+	require.NoError(t, err)
+
+	// Given: rotated key ring with the previous key retired
+	retired := previous.SigningKey()
+	retired.PrivateKey = nil
+	rotated, err := NewKeyRing(newTestKeyWithAlg(t, "ES256"), retired)
+	require.NoError(t, err)
+
+	// When:
+	actual := jwt.RegisteredClaims{}
+	err = parse(rotated, tokenString, &actual)
+
+	// Then:
+	assert.NoError(t, err)
+	assert.Equal(t, given, actual)
+
+	// When: previous key is no longer in the ring
+	err = parse(newTestKeyRing(t), tokenString, &jwt.RegisteredClaims{})
+
+	// Then:
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestParse_NoKeyID(t *testing.T) {
+	t.Parallel()
+
+	// Given: token signed before key IDs were introduced
+	ring := newTestKeyRing(t)
+	given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer}
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, given).
+		SignedString(ring.SigningKey().PrivateKey)
+	require.NoError(t, err)
+
+	// When:
+	actual := jwt.RegisteredClaims{}
+	err = parse(ring, tokenString, &actual)
+
+	// Then:
+	assert.NoError(t, err)
+	assert.Equal(t, given, actual)
+}
+
+func TestNewVerificationKeyRing(t *testing.T) {
+	t.Parallel()
+
+	// Given: token signed by an issuing replica
+	issuer := newTestKeyRing(t)
+	given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer}
+	tokenString, err := sign(context.Background(), issuer, given)
+	require.NoError(t, err)
+
+	// Given: verification-only key ring with the public key
+	pubKey := issuer.SigningKey()
+	pubKey.PrivateKey = nil
+
+	// When:
+	ring, err := NewVerificationKeyRing(pubKey)
+
+	// Then:
+	require.NoError(t, err)
+	assert.False(t, ring.CanSign())
+	assert.Equal(t, []Key{pubKey}, ring.Keys())
+
+	// When: token is verified
+	actual := jwt.RegisteredClaims{}
+	err = parse(ring, tokenString, &actual)
+
+	// Then:
+	assert.NoError(t, err)
+	assert.Equal(t, given, actual)
+
+	// When: token is signed
+	_, err = sign(context.Background(), ring, given)
+
+	// Then:
+	assert.Error(t, err)
+
+	// When: token without key ID is verified
+	noKeyID, err := jwt.NewWithClaims(jwt.SigningMethodRS256, given).SignedString(issuer.SigningKey().PrivateKey)
+	require.NoError(t, err)
+	err = parse(ring, noKeyID, &jwt.RegisteredClaims{})
+
+	// Then:
+	assert.Equal(t, ErrInvalidToken, err)
+
+	// When: no keys
+	_, err = NewVerificationKeyRing()
+
+	// Then:
+	assert.Error(t, err)
+}
+
+func newTestKey(t *testing.T) Key {
+	t.Helper()
+
+	return newTestKeyWithAlg(t, "RS256")
+}
+
+func newTestKeyWithAlg(t *testing.T, alg string) Key {
+	t.Helper()
+
+	key, err := GenerateKey(alg)
+	require.NoError(t, err)
+
+	return key
+}
+
+func newTestKeyRing(t *testing.T) *KeyRing {
+	t.Helper()
+
+	k, err := NewKeyRing(newTestKey(t))
+	require.NoError(t, err)
+
+	return k
+}
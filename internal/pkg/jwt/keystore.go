@@ -0,0 +1,38 @@
+package jwt
+
+import (
+	"sync/atomic"
+)
+
+var (
+	defaultKeyStore = &KeyStore{}
+)
+
+// KeyStore holds the current key ring
+// The key ring is swapped atomically so that in-flight Sign and Parse calls always see a complete key ring.
+type KeyStore struct {
+	ring atomic.Pointer[KeyRing]
+}
+
+// NewKeyStore creates a key store holding the provided key ring
+func NewKeyStore(k *KeyRing) *KeyStore {
+	s := &KeyStore{}
+	s.Store(k)
+
+	return s
+}
+
+// DefaultKeyStore returns the key store used by the package-level functions
+func DefaultKeyStore() *KeyStore {
+	return defaultKeyStore
+}
+
+// KeyRing returns the current key ring, which is nil if the keys are not initialised
+func (s *KeyStore) KeyRing() *KeyRing {
+	return s.ring.Load()
+}
+
+// Store replaces the current key ring
+func (s *KeyStore) Store(k *KeyRing) {
+	s.ring.Store(k)
+}
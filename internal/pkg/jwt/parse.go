@@ -5,15 +5,86 @@ import (
 	"github.com/pkg/errors"
 )
 
+var (
+	defaultVerifier = &Verifier{keys: defaultKeyStore, policy: DefaultValidationPolicy()}
+)
+
+// Verifier validates and parses tokens with the keys of its key store
+type Verifier struct {
+	keys   *KeyStore
+	policy ValidationPolicy
+	// audience is the audience that the tokens must be issued for, if set
+	audience string
+}
+
+// NewVerifier creates a Verifier backed by the key store
+func NewVerifier(keys *KeyStore) (*Verifier, error) {
+	if keys == nil || keys.KeyRing() == nil {
+		return nil, errors.New("verifier requires an initialised key store")
+	}
+
+	return &Verifier{keys: keys, policy: DefaultValidationPolicy()}, nil
+}
+
+// DefaultVerifier returns the Verifier backed by the default key store
+func DefaultVerifier() *Verifier {
+	return defaultVerifier
+}
+
+// ForAudience returns a copy of the Verifier that only accepts tokens issued for the audience
+func (v *Verifier) ForAudience(audience string) *Verifier {
+	result := *v
+	result.audience = audience
+
+	return &result
+}
+
+// WithPolicy returns a copy of the Verifier that validates the claims with the policy
+func (v *Verifier) WithPolicy(policy ValidationPolicy) *Verifier {
+	result := *v
+	result.policy = policy
+
+	return &result
+}
+
 // Parse validates and parses the token string
+func (v *Verifier) Parse(tokenString string, c jwt.Claims) error {
+	if err := parseWithPolicy(v.keys.KeyRing(), v.policy, tokenString, c); err != nil {
+		return err
+	}
+
+	return verifyAudience(c, v.audience)
+}
+
+// Parse validates and parses the token string with the default verifier
 func Parse(tokenString string, c jwt.Claims) error {
-	token, err := jwt.ParseWithClaims(tokenString, c, func(t *jwt.Token) (interface{}, error) {
-		// Validate alg
-		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+	return defaultVerifier.Parse(tokenString, c)
+}
+
+func parse(k *KeyRing, tokenString string, c jwt.Claims) error {
+	return parseWithPolicy(k, DefaultValidationPolicy(), tokenString, c)
+}
+
+func parseWithPolicy(k *KeyRing, policy ValidationPolicy, tokenString string, c jwt.Claims) error {
+	if k == nil {
+		return ErrInvalidToken
+	}
+
+	// The claims are validated by the policy instead, so that it applies to any claims type
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+
+	token, err := parser.ParseWithClaims(tokenString, c, func(t *jwt.Token) (interface{}, error) {
+		key, err := verificationKey(k, t)
+		if err != nil {
+			return nil, err
+		}
+
+		// Validate alg against the one the key is pinned to
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, errors.Errorf("Unexpected signing method: %v", t.Header["alg"])
 		}
 
-		return verifyKey, nil
+		return key.PublicKey, nil
 	})
 	if err != nil {
 		return ErrInvalidToken
@@ -23,15 +94,56 @@ func Parse(tokenString string, c jwt.Claims) error {
 		return ErrInvalidToken
 	}
 
-	mc, ok := c.(*jwt.RegisteredClaims)
-	if !ok {
+	// The signature is verified, decode the registered claims whatever the claims type is
+	var rc jwt.RegisteredClaims
+	if _, _, err := parser.ParseUnverified(tokenString, &rc); err != nil {
+		return ErrInvalidToken
+	}
+	if err := policy.validate(rc, jwt.TimeFunc()); err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// verifyAudience checks that the `aud` claim contains the audience, if set
+func verifyAudience(c jwt.Claims, audience string) error {
+	if audience == "" {
 		return nil
 	}
 
-	// Best-effort validation of issuer
-	if mc.Issuer != Issuer {
+	// Claims embedding jwt.RegisteredClaims also implement this
+	ac, ok := c.(interface {
+		VerifyAudience(cmp string, req bool) bool
+	})
+	if !ok || !ac.VerifyAudience(audience, true) {
 		return ErrInvalidToken
 	}
 
 	return nil
 }
+
+// verificationKey selects the key using the `kid` header
+func verificationKey(k *KeyRing, t *jwt.Token) (Key, error) {
+	kid, ok := t.Header["kid"]
+	if !ok {
+		// Tokens signed before key IDs were introduced can only be verified by the active key
+		if !k.CanSign() {
+			return Key{}, errors.New("Missing key id")
+		}
+
+		return k.SigningKey(), nil
+	}
+
+	s, ok := kid.(string)
+	if !ok {
+		return Key{}, errors.Errorf("Invalid key id: %v", kid)
+	}
+
+	key, ok := k.VerificationKey(s)
+	if !ok {
+		return Key{}, errors.Errorf("Unknown key id: %s", s)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,88 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParsePrivateKeyFromPEM parses a PEM encoded RSA, EC or Ed25519 private key
+// PKCS #8, PKCS #1 (RSA) and SEC 1 (EC) encodings are supported
+func ParsePrivateKeyFromPEM(b []byte) (crypto.Signer, error) {
+	return ParsePrivateKeyFromPEMWithPassphrase(b, nil)
+}
+
+// ParsePrivateKeyFromPEMWithPassphrase parses a PEM encoded private key that may be an encrypted PKCS #8 key
+// The passphrase is ignored if the key is not encrypted.
+func ParsePrivateKeyFromPEMWithPassphrase(b, passphrase []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("key must be a PEM encoded private key")
+	}
+
+	if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		return nil, errors.New("legacy encrypted PEM is not supported, convert the key to encrypted PKCS #8")
+	}
+
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		if len(passphrase) == 0 {
+			return nil, errors.New("private key is encrypted but no passphrase is configured")
+		}
+
+		der, err := decryptPKCS8(block.Bytes, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, ErrIncorrectPassphrase
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.Errorf("unsupported private key type %T", key)
+		}
+
+		return signer, nil
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.Errorf("unsupported private key type %T", key)
+		}
+
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.Errorf("unsupported private key in PEM block %q", block.Type)
+}
+
+// ParsePublicKeyFromPEM parses a PEM encoded RSA, EC or Ed25519 public key
+// PKIX, PKCS #1 (RSA) and X.509 certificate encodings are supported
+func ParsePublicKeyFromPEM(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("key must be a PEM encoded public key")
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		return cert.PublicKey, nil
+	}
+
+	return nil, errors.Errorf("unsupported public key in PEM block %q", block.Type)
+}
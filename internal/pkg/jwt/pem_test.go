@@ -0,0 +1,143 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrivateKeyFromPEM(t *testing.T) {
+	t.Parallel()
+
+	rsaKey := newTestKey(t)
+	ecKey := newTestKeyWithAlg(t, "ES256")
+	edKey := newTestKeyWithAlg(t, "EdDSA")
+
+	testCases := []struct {
+		desc      string
+		key       Key
+		blockType string
+		encode    func(t *testing.T, k Key) []byte
+	}{
+		{
+			desc:      "PKCS #1 RSA",
+			key:       rsaKey,
+			blockType: "RSA PRIVATE KEY",
+			encode: func(t *testing.T, k Key) []byte {
+				return x509.MarshalPKCS1PrivateKey(k.PrivateKey.(*rsa.PrivateKey))
+			},
+		},
+		{
+			desc:      "SEC 1 EC",
+			key:       ecKey,
+			blockType: "EC PRIVATE KEY",
+			encode: func(t *testing.T, k Key) []byte {
+				b, err := x509.MarshalECPrivateKey(k.PrivateKey.(*ecdsa.PrivateKey))
+				require.NoError(t, err)
+
+				return b
+			},
+		},
+		{desc: "PKCS #8 RSA", key: rsaKey, blockType: "PRIVATE KEY", encode: marshalTestPKCS8},
+		{desc: "PKCS #8 EC", key: ecKey, blockType: "PRIVATE KEY", encode: marshalTestPKCS8},
+		{desc: "PKCS #8 Ed25519", key: edKey, blockType: "PRIVATE KEY", encode: marshalTestPKCS8},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			given := pem.EncodeToMemory(&pem.Block{Type: tc.blockType, Bytes: tc.encode(t, tc.key)})
+
+			// When:
+			actual, err := ParsePrivateKeyFromPEM(given)
+
+			// Then:
+			require.NoError(t, err)
+			assert.Equal(t, tc.key.PrivateKey, actual)
+		})
+	}
+}
+
+func TestParsePublicKeyFromPEM(t *testing.T) {
+	t.Parallel()
+
+	rsaKey := newTestKey(t)
+
+	testCases := []struct {
+		desc      string
+		key       Key
+		blockType string
+		encode    func(t *testing.T, k Key) []byte
+	}{
+		{
+			desc:      "PKCS #1 RSA",
+			key:       rsaKey,
+			blockType: "RSA PUBLIC KEY",
+			encode: func(t *testing.T, k Key) []byte {
+				return x509.MarshalPKCS1PublicKey(k.PublicKey.(*rsa.PublicKey))
+			},
+		},
+		{desc: "PKIX RSA", key: rsaKey, blockType: "PUBLIC KEY", encode: marshalTestPKIX},
+		{desc: "PKIX EC", key: newTestKeyWithAlg(t, "ES384"), blockType: "PUBLIC KEY", encode: marshalTestPKIX},
+		{desc: "PKIX Ed25519", key: newTestKeyWithAlg(t, "EdDSA"), blockType: "PUBLIC KEY", encode: marshalTestPKIX},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			given := pem.EncodeToMemory(&pem.Block{Type: tc.blockType, Bytes: tc.encode(t, tc.key)})
+
+			// When:
+			actual, err := ParsePublicKeyFromPEM(given)
+
+			// Then:
+			require.NoError(t, err)
+			assert.Equal(t, tc.key.PublicKey, actual)
+		})
+	}
+}
+
+func TestParseKeyFromPEM_Error(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	notPEM := []byte("INVALID")
+	notKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("INVALID")})
+
+	// When:
+	_, privErr1 := ParsePrivateKeyFromPEM(notPEM)
+	_, privErr2 := ParsePrivateKeyFromPEM(notKey)
+	_, pubErr1 := ParsePublicKeyFromPEM(notPEM)
+	_, pubErr2 := ParsePublicKeyFromPEM(notKey)
+
+	// Then:
+	assert.Error(t, privErr1)
+	assert.Error(t, privErr2)
+	assert.Error(t, pubErr1)
+	assert.Error(t, pubErr2)
+}
+
+func marshalTestPKCS8(t *testing.T, k Key) []byte {
+	b, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	require.NoError(t, err)
+
+	return b
+}
+
+func marshalTestPKIX(t *testing.T, k Key) []byte {
+	b, err := x509.MarshalPKIXPublicKey(k.PublicKey)
+	require.NoError(t, err)
+
+	return b
+}
@@ -0,0 +1,165 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"hash"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrIncorrectPassphrase is returned when an encrypted private key cannot be decrypted with the passphrase
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase")
+
+// maxPBKDF2Iterations bounds the work of deriving the key, so that a crafted key file cannot stall the startup
+// It is well above the OpenSSL default of 2048 and the current recommendations of a few hundred thousand.
+const maxPBKDF2Iterations = 10_000_000
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo is the RFC 5208 EncryptedPrivateKeyInfo
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params is the RFC 8018 PBES2-params
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params is the RFC 8018 PBKDF2-params
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 decrypts a PBES2 encrypted PKCS #8 private key (PEM block type "ENCRYPTED PRIVATE KEY")
+// PBKDF2 with HMAC-SHA1/SHA256/SHA384/SHA512 and AES-CBC are supported, which covers the OpenSSL defaults.
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, errors.Wrap(err, "encrypted private key")
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, errors.Errorf("unsupported private key encryption %s, only PBES2 is supported", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, errors.Wrap(err, "PBES2 parameters")
+	}
+
+	keyLen, err := aesKeyLength(params.EncryptionScheme.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, errors.Wrap(err, "AES-CBC parameters")
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid AES-CBC IV length")
+	}
+
+	key, err := deriveKey(params.KeyDerivationFunc, passphrase, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted private key length")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	// A wrong passphrase almost always shows up as invalid padding
+	return unpadPKCS7(plaintext)
+}
+
+func aesKeyLength(oid asn1.ObjectIdentifier) (int, error) {
+	switch {
+	case oid.Equal(oidAES128CBC):
+		return 16, nil
+	case oid.Equal(oidAES192CBC):
+		return 24, nil
+	case oid.Equal(oidAES256CBC):
+		return 32, nil
+	}
+
+	return 0, errors.Errorf("unsupported private key cipher %s, only AES-CBC is supported", oid)
+}
+
+// deriveKey derives the encryption key from the passphrase with PBKDF2
+func deriveKey(kdf pkix.AlgorithmIdentifier, passphrase []byte, keyLen int) ([]byte, error) {
+	if !kdf.Algorithm.Equal(oidPBKDF2) {
+		return nil, errors.Errorf("unsupported key derivation function %s, only PBKDF2 is supported", kdf.Algorithm)
+	}
+
+	var params pbkdf2Params
+	if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+		return nil, errors.Wrap(err, "PBKDF2 parameters")
+	}
+	if params.IterationCount <= 0 || params.IterationCount > maxPBKDF2Iterations {
+		return nil, errors.Errorf("invalid PBKDF2 iteration count %d, it must be between 1 and %d", params.IterationCount, maxPBKDF2Iterations)
+	}
+	if params.KeyLength != 0 && params.KeyLength != keyLen {
+		return nil, errors.New("PBKDF2 key length does not match the cipher")
+	}
+
+	var prf func() hash.Hash
+	switch oid := params.PRF.Algorithm; {
+	// hmacWithSHA1 is the default when the PRF is omitted
+	case len(oid) == 0, oid.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case oid.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case oid.Equal(oidHMACWithSHA384):
+		prf = sha512.New384
+	case oid.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, errors.Errorf("unsupported PBKDF2 function %s", oid)
+	}
+
+	return pbkdf2.Key(passphrase, params.Salt, params.IterationCount, keyLen, prf), nil
+}
+
+func unpadPKCS7(b []byte) ([]byte, error) {
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || n > len(b) {
+		return nil, ErrIncorrectPassphrase
+	}
+	for _, it := range b[len(b)-n:] {
+		if int(it) != n {
+			return nil, ErrIncorrectPassphrase
+		}
+	}
+
+	return b[:len(b)-n], nil
+}
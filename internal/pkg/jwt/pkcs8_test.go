@@ -0,0 +1,129 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrivateKeyFromPEMWithPassphrase(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		file     string
+		expected interface{}
+	}{
+		{file: "rsa_aes256_sha256.p8", expected: &rsa.PrivateKey{}},
+		{file: "ec_aes128_sha1.p8", expected: &ecdsa.PrivateKey{}},
+		{file: "ed25519_aes192_sha512.p8", expected: ed25519.PrivateKey{}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.file, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			given := readTestData(t, tc.file)
+
+			// When:
+			actual, err := ParsePrivateKeyFromPEMWithPassphrase(given, []byte("passphrase"))
+
+			// Then:
+			require.NoError(t, err)
+			assert.IsType(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParsePrivateKeyFromPEMWithPassphrase_Error(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc       string
+		file       string
+		passphrase string
+		expected   error
+	}{
+		{desc: "incorrect passphrase", file: "rsa_aes256_sha256.p8", passphrase: "incorrect", expected: ErrIncorrectPassphrase},
+		{desc: "no passphrase", file: "rsa_aes256_sha256.p8"},
+		{desc: "legacy encrypted PEM", file: "legacy_encrypted.pem", passphrase: "passphrase"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			given := readTestData(t, tc.file)
+
+			// When:
+			actual, err := ParsePrivateKeyFromPEMWithPassphrase(given, []byte(tc.passphrase))
+
+			// Then:
+			assert.Error(t, err)
+			if tc.expected != nil {
+				assert.ErrorIs(t, err, tc.expected)
+			}
+			assert.Nil(t, actual)
+		})
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc      string
+		given     pbkdf2Params
+		expectErr bool
+	}{
+		{desc: "default function", given: pbkdf2Params{Salt: []byte("salt"), IterationCount: 2048}},
+		{desc: "key length", given: pbkdf2Params{Salt: []byte("salt"), IterationCount: 2048, KeyLength: 32}},
+		{desc: "another key length", given: pbkdf2Params{Salt: []byte("salt"), IterationCount: 2048, KeyLength: 16}, expectErr: true},
+		{desc: "no iterations", given: pbkdf2Params{Salt: []byte("salt")}, expectErr: true},
+		{desc: "too many iterations", given: pbkdf2Params{Salt: []byte("salt"), IterationCount: maxPBKDF2Iterations + 1}, expectErr: true},
+		{
+			desc:      "unsupported function",
+			given:     pbkdf2Params{Salt: []byte("salt"), IterationCount: 2048, PRF: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			params, err := asn1.Marshal(tc.given)
+			require.NoError(t, err)
+			kdf := pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: params}}
+
+			// When:
+			actual, err := deriveKey(kdf, []byte("passphrase"), 32)
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			if !tc.expectErr {
+				assert.Len(t, actual, 32)
+			}
+		})
+	}
+}
+
+func readTestData(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	return b
+}
@@ -0,0 +1,51 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// minRSAKeyBits is the minimum accepted RSA modulus size
+// The supported elliptic curves and Ed25519 are all strong enough.
+const minRSAKeyBits = 2048
+
+// checkKeyStrength rejects public keys that are too weak
+func checkKeyStrength(pubKey crypto.PublicKey) error {
+	if k, ok := pubKey.(*rsa.PublicKey); ok && k.N.BitLen() < minRSAKeyBits {
+		return errors.Errorf("RSA key is %d bits, at least %d bits are required", k.N.BitLen(), minRSAKeyBits)
+	}
+
+	return nil
+}
+
+// checkKeyPair checks that the private key belongs to the public key
+func checkKeyPair(privKey crypto.Signer, pubKey crypto.PublicKey) error {
+	// All the supported public key types implement Equal
+	pub, ok := privKey.Public().(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok || !pub.Equal(pubKey) {
+		return errors.New("private key does not match the public key")
+	}
+
+	return nil
+}
+
+// selfTest signs and verifies a probe token to prove that the key, or its signing backend, can be used for signing
+func selfTest(k Key) error {
+	tokenString, err := signToken(context.Background(), k, jwt.NewWithClaims(k.Method, jwt.RegisteredClaims{Subject: "self-test"}))
+	if err != nil {
+		return errors.Wrap(err, "self-test sign")
+	}
+
+	_, err = jwt.Parse(tokenString, func(*jwt.Token) (interface{}, error) {
+		return k.PublicKey, nil
+	}, jwt.WithValidMethods([]string{k.Method.Alg()}))
+	if err != nil {
+		return errors.Wrap(err, "self-test verify")
+	}
+
+	return nil
+}
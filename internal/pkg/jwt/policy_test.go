@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKey_Policy(t *testing.T) {
+	t.Parallel()
+
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	key := newTestKey(t)
+	other := newTestKey(t)
+
+	testCases := []struct {
+		desc    string
+		privKey *rsa.PrivateKey
+		pubKey  *rsa.PublicKey
+	}{
+		{desc: "weak RSA key", privKey: weakKey, pubKey: &weakKey.PublicKey},
+		{desc: "weak RSA public key", pubKey: &weakKey.PublicKey},
+		{desc: "mismatched key pair", privKey: key.PrivateKey.(*rsa.PrivateKey), pubKey: other.PublicKey.(*rsa.PublicKey)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			var err error
+			if tc.privKey != nil {
+				_, err = NewKey("", tc.privKey, tc.pubKey)
+			} else {
+				_, err = NewKey("", nil, tc.pubKey)
+			}
+
+			// Then:
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range []string{"RS256", "ES256", "ES384", "EdDSA"} {
+		alg := alg
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			key := newTestKeyWithAlg(t, alg)
+
+			// When:
+			err := selfTest(key)
+
+			// Then:
+			assert.NoError(t, err)
+		})
+	}
+
+	// Given: public key that does not verify the signatures of the private key
+	key := newTestKey(t)
+	key.PublicKey = newTestKey(t).PublicKey
+
+	// When:
+	err := selfTest(key)
+
+	// Then:
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package jwt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/severedsea/golang-kit/logr"
+	"golang.org/x/exp/slices"
+)
+
+// Reloader reloads the key ring from the key source on SIGHUP or on a polling interval
+type Reloader struct {
+	store    *KeyStore
+	source   KeySource
+	interval time.Duration
+}
+
+// NewReloader creates a Reloader that swaps the key ring in the store
+// Polling is disabled if the interval is zero.
+func NewReloader(store *KeyStore, source KeySource, interval time.Duration) *Reloader {
+	return &Reloader{
+		store:    store,
+		source:   source,
+		interval: interval,
+	}
+}
+
+// Reload loads the key ring from the source and swaps it into the store
+// The current key ring is kept if the source fails to load.
+func (r *Reloader) Reload() (bool, error) {
+	k, err := r.source.Load()
+	if err != nil {
+		return false, err
+	}
+
+	current := r.store.KeyRing()
+	if current != nil && slices.Equal(keyIDs(current), keyIDs(k)) {
+		return false, nil
+	}
+
+	r.store.Store(k)
+
+	return true, nil
+}
+
+// Run reloads the key ring on SIGHUP or on every interval until the context is done
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	r.run(ctx, hup, tick)
+}
+
+func (r *Reloader) run(ctx context.Context, hup <-chan os.Signal, tick <-chan time.Time) {
+	logger := logr.GetLogger(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logger.Infof("[jwt] SIGHUP received. Reloading keys")
+		case <-tick:
+		}
+
+		changed, err := r.Reload()
+		if err != nil {
+			logger.Errorf("[jwt] Keys not reloaded, keeping the current keys: %s", err)
+			continue
+		}
+		if changed {
+			logger.WithField("kid", r.store.KeyRing().SigningKey().ID).
+				Infof("[jwt] Keys reloaded")
+		}
+	}
+}
+
+// keyIDs returns the IDs of all the keys in the ring, starting with the active key
+func keyIDs(k *KeyRing) []string {
+	keys := k.Keys()
+
+	result := make([]string, 0, len(keys))
+	for _, it := range keys {
+		result = append(result, it.ID)
+	}
+
+	return result
+}
@@ -0,0 +1,151 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	current := newTestKeyRing(t)
+	next := newTestKeyRing(t)
+	store := NewKeyStore(current)
+
+	testCases := []struct {
+		desc            string
+		source          KeySource
+		expectedChanged bool
+		expectedErr     bool
+		expected        *KeyRing
+	}{
+		{
+			desc:     "same keys",
+			source:   &stubKeySource{ring: current},
+			expected: current,
+		},
+		{
+			desc:        "load error keeps the current keys",
+			source:      &stubKeySource{err: errors.New("invalid key")},
+			expectedErr: true,
+			expected:    current,
+		},
+		{
+			desc:            "new keys",
+			source:          &stubKeySource{ring: next},
+			expectedChanged: true,
+			expected:        next,
+		},
+	}
+
+	// Sequential as the test cases share the same store
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// When:
+			changed, err := NewReloader(store, tc.source, 0).Reload()
+
+			// Then:
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedChanged, changed)
+			assert.Same(t, tc.expected, store.KeyRing())
+		})
+	}
+}
+
+func TestReloader_Run(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	store := NewKeyStore(newTestKeyRing(t))
+	next := newTestKeyRing(t)
+	hup := make(chan os.Signal)
+	tick := make(chan time.Time)
+	source := &stubKeySource{err: errors.New("invalid key")}
+	r := NewReloader(store, source, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.run(ctx, hup, tick)
+		close(done)
+	}()
+
+	// When: bad key on SIGHUP
+	current := store.KeyRing()
+	hup <- syscall.SIGHUP
+	hup <- syscall.SIGHUP // blocks until the previous reload is done
+
+	// Then:
+	assert.Same(t, current, store.KeyRing())
+
+	// When: new key on the interval
+	source.set(next, nil)
+	tick <- time.Now()
+	cancel()
+	<-done
+
+	// Then:
+	assert.Same(t, next, store.KeyRing())
+}
+
+func TestKeyStore_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	rings := []*KeyRing{newTestKeyRing(t), newTestKeyRing(t)}
+	store := NewKeyStore(rings[0])
+	given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer}
+
+	// When: keys are swapped while signing and parsing
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			store.Store(rings[i%2])
+		}(i)
+		go func() {
+			defer wg.Done()
+
+			k := store.KeyRing()
+			tokenString, err := sign(context.Background(), k, given)
+			assert.NoError(t, err)
+			assert.NoError(t, parse(k, tokenString, &jwt.RegisteredClaims{}))
+		}()
+	}
+	wg.Wait()
+
+	// Then:
+	require.NotNil(t, store.KeyRing())
+}
+
+// stubKeySource is the stub key source
+type stubKeySource struct {
+	mu   sync.Mutex
+	ring *KeyRing
+	err  error
+}
+
+func (s *stubKeySource) set(ring *KeyRing, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ring, s.err = ring, err
+}
+
+func (s *stubKeySource) Load() (*KeyRing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ring, s.err
+}
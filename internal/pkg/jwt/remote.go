@@ -0,0 +1,281 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/projectpath"
+)
+
+const (
+	// remoteTimeout bounds every call to the signing service, which is on the login path
+	remoteTimeout = 5 * time.Second
+	// unixScheme is the URL scheme of signing services listening on a Unix socket, e.g. unix:///run/signerd.sock
+	unixScheme = "unix://"
+)
+
+// SigningBackend signs the JWS signing input with a private key held outside serverd, e.g. by a signing service or a KMS
+type SigningBackend interface {
+	// Sign returns the signature of the signing input with the key, which must be the backend's active key
+	Sign(ctx context.Context, kid string, signingInput []byte) ([]byte, error)
+}
+
+// SignRequest is the request body of the signing service's POST /v1/sign
+type SignRequest struct {
+	KeyID string `json:"kid"`
+	// SigningInput is the base64url encoded JWS signing input
+	SigningInput string `json:"signing_input"`
+}
+
+// SignResponse is the response body of the signing service's POST /v1/sign
+type SignResponse struct {
+	// Signature is the base64url encoded JWS signature
+	Signature string `json:"signature"`
+}
+
+// HTTPSigningBackend signs with a signing service over HTTP or a Unix socket, see cmd/signerd
+//
+// The signing service serves:
+//   - GET /v1/keys: JSON Web Key Set of its public keys, starting with the active key
+//   - POST /v1/sign: SignRequest and SignResponse
+type HTTPSigningBackend struct {
+	baseURL string
+	client  *http.Client
+}
+
+// RemoteOption configures how the HTTPSigningBackend authenticates to the signing service
+type RemoteOption func(o *remoteOptions)
+
+type remoteOptions struct {
+	secret    string
+	tlsConfig *tls.Config
+}
+
+// WithSignerSecret sends the shared secret of the signing service as a bearer token on every request
+func WithSignerSecret(secret string) RemoteOption {
+	return func(o *remoteOptions) {
+		o.secret = secret
+	}
+}
+
+// WithSignerTLS sets the TLS config of an https:// signing service, e.g. its CA and the client certificate for mTLS
+func WithSignerTLS(cfg *tls.Config) RemoteOption {
+	return func(o *remoteOptions) {
+		o.tlsConfig = cfg
+	}
+}
+
+// NewHTTPSigningBackend creates a signing backend for the signing service at the http(s):// or unix:// URL
+func NewHTTPSigningBackend(rawURL string, opts ...RemoteOption) (*HTTPSigningBackend, error) {
+	var o remoteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = o.tlsConfig
+
+	var baseURL string
+	switch {
+	case strings.HasPrefix(rawURL, unixScheme):
+		socket := strings.TrimPrefix(rawURL, unixScheme)
+		if socket == "" {
+			return nil, errors.New("signer socket path is empty")
+		}
+
+		var dialer net.Dialer
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		// The host is ignored as every request is sent to the socket
+		baseURL = "http://signer"
+
+	case strings.HasPrefix(rawURL, "http://"), strings.HasPrefix(rawURL, "https://"):
+		baseURL = strings.TrimSuffix(rawURL, "/")
+
+	default:
+		return nil, errors.Errorf("unsupported signer URL %s", rawURL)
+	}
+
+	var rt http.RoundTripper = transport
+	if o.secret != "" {
+		rt = secretTransport{secret: o.secret, next: transport}
+	}
+
+	return &HTTPSigningBackend{baseURL: baseURL, client: &http.Client{Timeout: remoteTimeout, Transport: rt}}, nil
+}
+
+// secretTransport sends the shared secret of the signing service as a bearer token
+type secretTransport struct {
+	secret string
+	next   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t secretTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.secret)
+
+	return t.next.RoundTrip(req)
+}
+
+// SignerSecretMatches checks in constant time if the Authorization header of the request has the shared secret
+func SignerSecretMatches(r *http.Request, secret string) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// signerOptionsFromEnv returns how serverd authenticates to the signing service
+//   - JWT_SIGNER_SECRET: shared secret of the signing service, see SIGNER_SECRET of cmd/signerd
+//   - JWT_SIGNER_TLS_CA_PATH: PEM bundle of the CAs that are trusted instead of the system ones
+//   - JWT_SIGNER_TLS_CERT_PATH and JWT_SIGNER_TLS_KEY_PATH: PEM client certificate and key for mTLS
+func signerOptionsFromEnv() ([]RemoteOption, error) {
+	var opts []RemoteOption
+	if secret := os.Getenv("JWT_SIGNER_SECRET"); secret != "" {
+		opts = append(opts, WithSignerSecret(secret))
+	}
+
+	caPath := os.Getenv("JWT_SIGNER_TLS_CA_PATH")
+	certPath, keyPath := os.Getenv("JWT_SIGNER_TLS_CERT_PATH"), os.Getenv("JWT_SIGNER_TLS_KEY_PATH")
+	if caPath == "" && certPath == "" && keyPath == "" {
+		return opts, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caPath != "" {
+		b, err := os.ReadFile(projectpath.Abs(caPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "JWT_SIGNER_TLS_CA_PATH")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("JWT_SIGNER_TLS_CA_PATH has no PEM certificate: %s", caPath)
+		}
+		cfg.RootCAs = pool
+	}
+	if certPath != "" || keyPath != "" {
+		if certPath == "" || keyPath == "" {
+			return nil, errors.New("JWT_SIGNER_TLS_CERT_PATH and JWT_SIGNER_TLS_KEY_PATH must be both set")
+		}
+
+		cert, err := tls.LoadX509KeyPair(projectpath.Abs(certPath), projectpath.Abs(keyPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "JWT_SIGNER_TLS_CERT_PATH")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return append(opts, WithSignerTLS(cfg)), nil
+}
+
+// Sign implements SigningBackend
+func (b *HTTPSigningBackend) Sign(ctx context.Context, kid string, signingInput []byte) ([]byte, error) {
+	body, err := json.Marshal(SignRequest{
+		KeyID:        kid,
+		SigningInput: encodeBase64URL(signingInput),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/v1/sign", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "signer")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("signer: unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var v SignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "signer")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(v.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "signer signature")
+	}
+
+	return sig, nil
+}
+
+// Keys returns the public keys of the signing service, starting with the active key
+func (b *HTTPSigningBackend) Keys(ctx context.Context) (JSONWebKeySet, error) {
+	v, err := fetchJWKS(ctx, b.client, b.baseURL+"/v1/keys")
+	if err != nil {
+		return JSONWebKeySet{}, errors.Wrap(err, "signer")
+	}
+
+	return v, nil
+}
+
+// RemoteKeySource loads the keys of a signing service, which keeps the private key outside serverd
+// The active key signs with the signing service. The other keys are verification-only keys.
+type RemoteKeySource struct {
+	URL     string
+	backend *HTTPSigningBackend
+}
+
+// NewRemoteKeySource creates a key source for the signing service at the http(s):// or unix:// URL
+func NewRemoteKeySource(rawURL string, opts ...RemoteOption) (RemoteKeySource, error) {
+	backend, err := NewHTTPSigningBackend(rawURL, opts...)
+	if err != nil {
+		return RemoteKeySource{}, err
+	}
+
+	return RemoteKeySource{URL: rawURL, backend: backend}, nil
+}
+
+// Load implements KeySource
+func (s RemoteKeySource) Load() (*KeyRing, error) {
+	if s.backend == nil {
+		return nil, errors.New("signer: use NewRemoteKeySource")
+	}
+
+	v, err := s.backend.Keys(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := newKeysFromJWKS(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("signer: no keys")
+	}
+
+	active := keys[0]
+	active.Backend = s.backend
+
+	// The self-test proves that the signing service holds the private key of the published active key
+	k, err := NewKeyRing(active, keys[1:]...)
+	if err != nil {
+		return nil, errors.Wrap(err, "signer")
+	}
+
+	return k, nil
+}
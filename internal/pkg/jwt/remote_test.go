@@ -0,0 +1,158 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/severedsea/golang-kit/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteKeySource_Load(t *testing.T) {
+	t.Parallel()
+
+	// Given: signing service with an active and a retired key
+	retired := newTestKeyWithAlg(t, "ES384")
+	retired.PrivateKey = nil
+	remote, err := NewKeyRing(newTestKeyWithAlg(t, "ES256"), retired)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc   string
+		listen func(t *testing.T) (*httptest.Server, string)
+	}{
+		{
+			desc: "http",
+			listen: func(t *testing.T) (*httptest.Server, string) {
+				srv := httptest.NewServer(newTestSigningService(t, remote, remote.SigningKey()))
+
+				return srv, srv.URL
+			},
+		},
+		{
+			desc: "unix socket",
+			listen: func(t *testing.T) (*httptest.Server, string) {
+				socket := filepath.Join(t.TempDir(), "signerd.sock")
+				l, err := net.Listen("unix", socket)
+				require.NoError(t, err)
+
+				srv := httptest.NewUnstartedServer(newTestSigningService(t, remote, remote.SigningKey()))
+				srv.Listener = l
+				srv.Start()
+
+				return srv, "unix://" + socket
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			srv, url := tc.listen(t)
+			defer srv.Close()
+			s, err := NewRemoteKeySource(url)
+			require.NoError(t, err)
+
+			// When:
+			actual, err := s.Load()
+
+			// Then:
+			require.NoError(t, err)
+			assert.True(t, actual.CanSign())
+			assert.Nil(t, actual.SigningKey().PrivateKey)
+			assert.Equal(t, keyIDs(remote), keyIDs(actual))
+
+			// When: token is signed by the signing service
+			given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer}
+			tokenString, err := sign(context.Background(), actual, given)
+			require.NoError(t, err)
+
+			// Then: token is verified with the public key
+			claims := jwt.RegisteredClaims{}
+			assert.NoError(t, parse(remote, tokenString, &claims))
+			assert.Equal(t, given, claims)
+
+			// When: the request is cancelled
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = sign(ctx, actual, given)
+
+			// Then: the signing service is not called
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), context.Canceled.Error())
+		})
+	}
+}
+
+func TestRemoteKeySource_Load_Error(t *testing.T) {
+	t.Parallel()
+
+	// Given: signing service that signs with a key other than the published active key
+	remote := newTestKeyRing(t)
+	srv := httptest.NewServer(newTestSigningService(t, remote, newTestKey(t)))
+	defer srv.Close()
+	s, err := NewRemoteKeySource(srv.URL)
+	require.NoError(t, err)
+
+	// When:
+	actual, err := s.Load()
+
+	// Then:
+	assert.Error(t, err)
+	assert.Nil(t, actual)
+
+	// When: signing service is down
+	srv.Close()
+	actual, err = s.Load()
+
+	// Then:
+	assert.Error(t, err)
+	assert.Nil(t, actual)
+}
+
+func TestNewHTTPSigningBackend_Error(t *testing.T) {
+	t.Parallel()
+
+	for _, it := range []string{"", "unix://", "tcp://localhost:3001", "localhost:3001"} {
+		// When:
+		actual, err := NewHTTPSigningBackend(it)
+
+		// Then:
+		assert.Error(t, err, it)
+		assert.Nil(t, actual, it)
+	}
+}
+
+// newTestSigningService returns a stub of the signing service protocol that signs with the provided key
+func newTestSigningService(t *testing.T, keys *KeyRing, signKey Key) http.Handler {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/keys", func(w http.ResponseWriter, r *http.Request) {
+		web.RespondJSON(r.Context(), w, keys.JWKS(), nil)
+	})
+	mux.HandleFunc("/v1/sign", func(w http.ResponseWriter, r *http.Request) {
+		var req SignRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		signingInput, err := base64.RawURLEncoding.DecodeString(req.SigningInput)
+		require.NoError(t, err)
+
+		sig, err := signKey.Sign(context.Background(), signingInput)
+		require.NoError(t, err)
+
+		web.RespondJSON(r.Context(), w, SignResponse{Signature: encodeBase64URL(sig)}, nil)
+	})
+
+	return mux
+}
@@ -1,19 +1,80 @@
 package jwt
 
 import (
+	"context"
+
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
 	"github.com/severedsea/golang-kit/web"
 )
 
+var (
+	defaultSigner = &Signer{keys: defaultKeyStore}
+)
+
+// Signer signs JWT claims with the active key of its key store
+type Signer struct {
+	keys *KeyStore
+}
+
+// NewSigner creates a Signer backed by the key store
+func NewSigner(keys *KeyStore) (*Signer, error) {
+	if keys == nil || keys.KeyRing() == nil {
+		return nil, errors.New("signer requires an initialised key store")
+	}
+
+	return &Signer{keys: keys}, nil
+}
+
+// DefaultSigner returns the Signer backed by the default key store
+func DefaultSigner() *Signer {
+	return defaultSigner
+}
+
 // Sign signs the JWT claims and returns the JWT string
-func Sign(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+// The context bounds the call to the signing backend of the active key, if any.
+func (s *Signer) Sign(ctx context.Context, claims jwt.Claims) (string, error) {
+	return sign(ctx, s.keys.KeyRing(), claims)
+}
+
+// Sign signs the JWT claims with the default signer and returns the JWT string
+func Sign(ctx context.Context, claims jwt.Claims) (string, error) {
+	return defaultSigner.Sign(ctx, claims)
+}
+
+func sign(ctx context.Context, k *KeyRing, claims jwt.Claims) (string, error) {
+	if k == nil {
+		return "", web.NewError(ErrJWT, "keys are not initialised")
+	}
+	if !k.CanSign() {
+		return "", web.NewError(ErrJWT, "keys are verification-only")
+	}
+
+	key := k.SigningKey()
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
 
 	// Sign claims
-	tokenString, err := token.SignedString(signKey)
+	tokenString, err := signToken(ctx, key, token)
 	if err != nil {
 		return "", web.NewError(ErrJWT, err.Error())
 	}
 
 	return tokenString, nil
 }
+
+// signToken signs the token with the key, which only hands the signing input to the signing backend
+func signToken(ctx context.Context, key Key, token *jwt.Token) (string, error) {
+	signingString, err := token.SigningString()
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := key.Sign(ctx, []byte(signingString))
+	if err != nil {
+		return "", err
+	}
+
+	return signingString + "." + encodeBase64URL(sig), nil
+}
@@ -1,12 +1,15 @@
 package jwt
 
 import (
+	"context"
+
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/severedsea/golang-kit/web"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSign(t *testing.T) {
@@ -19,7 +22,7 @@ func TestSign(t *testing.T) {
 	}
 
 	// When:
-	actual, err := Sign(given)
+	actual, err := Sign(context.Background(), given)
 
 	// Then:
 	assert.NoError(t, err)
@@ -81,7 +84,7 @@ func TestParse(t *testing.T) {
 			t.Parallel()
 
 			// Given:
-			given, err := Sign(tc.given)
+			given, err := Sign(context.Background(), tc.given)
 			assert.NoError(t, err)
 
 			// When:
@@ -109,3 +112,68 @@ func TestParse_Invalid_Error(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, ErrInvalidToken, err)
 }
+
+func TestSigner_Verifier(t *testing.T) {
+	t.Parallel()
+
+	// Given: two issuers in the same process
+	keysA := NewKeyStore(newTestKeyRing(t))
+	signerA, err := NewSigner(keysA)
+	require.NoError(t, err)
+	verifierA, err := NewVerifier(keysA)
+	require.NoError(t, err)
+	verifierB, err := NewVerifier(NewKeyStore(newTestKeyRing(t)))
+	require.NoError(t, err)
+	given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer}
+
+	// When:
+	tokenString, err := signerA.Sign(context.Background(), given)
+	require.NoError(t, err)
+
+	// Then:
+	actual := jwt.RegisteredClaims{}
+	assert.NoError(t, verifierA.Parse(tokenString, &actual))
+	assert.Equal(t, given, actual)
+	assert.Equal(t, ErrInvalidToken, verifierB.Parse(tokenString, &jwt.RegisteredClaims{}))
+	assert.Equal(t, ErrInvalidToken, Parse(tokenString, &jwt.RegisteredClaims{}), "default verifier has other keys")
+}
+
+func TestVerifier_ForAudience(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	keys := NewKeyStore(newTestKeyRing(t))
+	signer, err := NewSigner(keys)
+	require.NoError(t, err)
+	verifier, err := NewVerifier(keys)
+	require.NoError(t, err)
+	given := jwt.RegisteredClaims{Subject: "subject", Issuer: Issuer, Audience: jwt.ClaimStrings{"orders"}}
+	tokenString, err := signer.Sign(context.Background(), given)
+	require.NoError(t, err)
+
+	// When:
+	actual := jwt.RegisteredClaims{}
+	err = verifier.ForAudience("orders").Parse(tokenString, &actual)
+
+	// Then:
+	assert.NoError(t, err)
+	assert.Equal(t, given, actual)
+	assert.NoError(t, verifier.Parse(tokenString, &jwt.RegisteredClaims{}), "audience is not enforced")
+	assert.Equal(t, ErrInvalidToken, verifier.ForAudience("payments").Parse(tokenString, &jwt.RegisteredClaims{}))
+}
+
+func TestNewSigner_NewVerifier_Error(t *testing.T) {
+	t.Parallel()
+
+	for _, keys := range []*KeyStore{nil, {}} {
+		// When:
+		signer, signerErr := NewSigner(keys)
+		verifier, verifierErr := NewVerifier(keys)
+
+		// Then:
+		assert.Error(t, signerErr)
+		assert.Nil(t, signer)
+		assert.Error(t, verifierErr)
+		assert.Nil(t, verifier)
+	}
+}
@@ -0,0 +1,451 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/projectpath"
+	"github.com/severedsea/jwt-server/internal/pkg/stringsx"
+)
+
+// KeySource loads the key material for the key ring
+type KeySource interface {
+	Load() (*KeyRing, error)
+}
+
+// KeySourceFromEnv returns the key source configured by the JWT_* env vars
+// See VerifyOnlyKeySourceFromEnv for the key source in verify-only mode.
+//
+// In order of precedence:
+//   - JWT_SIGNER_URL: signing service holding the private key, see cmd/signerd and signerOptionsFromEnv
+//   - JWT_KEY_DIR: directory of PEM files
+//   - JWT_PRIVATE_KEY: inline PEM or base64 encoded PEM
+//   - JWT_PRIVATE_KEY_PATH: PEM files
+//
+// An encrypted private key is decrypted with JWT_PRIVATE_KEY_PASSPHRASE or the file at JWT_PRIVATE_KEY_PASSPHRASE_PATH.
+func KeySourceFromEnv() (KeySource, error) {
+	alg := os.Getenv("JWT_SIGNING_ALG")
+	passphrase := Passphrase{
+		Value: os.Getenv("JWT_PRIVATE_KEY_PASSPHRASE"),
+		Path:  os.Getenv("JWT_PRIVATE_KEY_PASSPHRASE_PATH"),
+	}
+	if passphrase.Value != "" && passphrase.Path != "" {
+		return nil, errors.New("only one of JWT_PRIVATE_KEY_PASSPHRASE or JWT_PRIVATE_KEY_PASSPHRASE_PATH can be configured")
+	}
+
+	switch {
+	case os.Getenv("JWT_SIGNER_URL") != "":
+		opts, err := signerOptionsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+
+		return NewRemoteKeySource(os.Getenv("JWT_SIGNER_URL"), opts...)
+
+	case os.Getenv("JWT_KEY_DIR") != "":
+		return DirKeySource{
+			Algorithm:  alg,
+			Dir:        os.Getenv("JWT_KEY_DIR"),
+			Passphrase: passphrase,
+		}, nil
+
+	case os.Getenv("JWT_PRIVATE_KEY") != "":
+		return EnvKeySource{
+			Algorithm:         alg,
+			PrivateKey:        os.Getenv("JWT_PRIVATE_KEY"),
+			PublicKey:         os.Getenv("JWT_PUBLIC_KEY"),
+			RetiredPublicKeys: os.Getenv("JWT_RETIRED_PUBLIC_KEYS"),
+			Passphrase:        passphrase,
+		}, nil
+
+	case os.Getenv("JWT_PRIVATE_KEY_PATH") != "":
+		return FileKeySource{
+			Algorithm:             alg,
+			PrivateKeyPath:        os.Getenv("JWT_PRIVATE_KEY_PATH"),
+			PublicKeyPath:         os.Getenv("JWT_PUBLIC_KEY_PATH"),
+			RetiredPublicKeyPaths: stringsx.SplitList(os.Getenv("JWT_RETIRED_PUBLIC_KEY_PATHS")),
+			Passphrase:            passphrase,
+		}, nil
+	}
+
+	return nil, errors.New("none of JWT_SIGNER_URL, JWT_KEY_DIR, JWT_PRIVATE_KEY or JWT_PRIVATE_KEY_PATH is configured")
+}
+
+// VerifyOnlyFromEnv returns true if JWT_VERIFY_ONLY is set, in which case no private key may be configured
+func VerifyOnlyFromEnv() (bool, error) {
+	v := os.Getenv("JWT_VERIFY_ONLY")
+	if v == "" {
+		return false, nil
+	}
+
+	verifyOnly, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.Wrap(err, "JWT_VERIFY_ONLY")
+	}
+
+	return verifyOnly, nil
+}
+
+// VerifyOnlyKeySourceFromEnv returns the verification-only key source configured by the JWT_* env vars
+//
+// In order of precedence:
+//   - JWT_JWKS_URL: JSON Web Key Set of the issuing replicas
+//   - JWT_PUBLIC_KEY and JWT_RETIRED_PUBLIC_KEYS: inline PEM or base64 encoded PEM
+//   - JWT_PUBLIC_KEY_PATH and JWT_RETIRED_PUBLIC_KEY_PATHS: PEM files
+func VerifyOnlyKeySourceFromEnv() (KeySource, error) {
+	for _, it := range []string{"JWT_SIGNER_URL", "JWT_KEY_DIR", "JWT_PRIVATE_KEY", "JWT_PRIVATE_KEY_PATH", "JWT_KEY_ROTATION_INTERVAL"} {
+		if os.Getenv(it) != "" {
+			return nil, errors.Errorf("%s cannot be configured in verify-only mode", it)
+		}
+	}
+
+	if url := os.Getenv("JWT_JWKS_URL"); url != "" {
+		return JWKSKeySource{URL: url}, nil
+	}
+
+	s := PublicKeySource{
+		PublicKeys:     []string{os.Getenv("JWT_PUBLIC_KEY"), os.Getenv("JWT_RETIRED_PUBLIC_KEYS")},
+		PublicKeyPaths: stringsx.SplitList(os.Getenv("JWT_PUBLIC_KEY_PATH") + "," + os.Getenv("JWT_RETIRED_PUBLIC_KEY_PATHS")),
+	}
+	if strings.TrimSpace(strings.Join(s.PublicKeys, "")) == "" && len(s.PublicKeyPaths) == 0 {
+		return nil, errors.New("none of JWT_JWKS_URL, JWT_PUBLIC_KEY or JWT_PUBLIC_KEY_PATH is configured")
+	}
+
+	return s, nil
+}
+
+// InitKeySource initialises the default key store from the key source
+func InitKeySource(s KeySource) error {
+	k, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	defaultKeyStore.Store(k)
+
+	return nil
+}
+
+// LoadKeyStore creates a key store from the key source
+func LoadKeyStore(s KeySource) (*KeyStore, error) {
+	k, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyStore(k), nil
+}
+
+// Passphrase decrypts an encrypted PKCS #8 private key
+// It is either the value or the contents of the file at the path, without the trailing new line.
+type Passphrase struct {
+	Value string
+	Path  string
+}
+
+// load returns the passphrase, which is empty if not configured
+// The file is read on every load so that a rotated passphrase is picked up on reload.
+func (p Passphrase) load() ([]byte, error) {
+	if p.Path == "" {
+		return []byte(p.Value), nil
+	}
+
+	b, err := readKeyFile(p.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "passphrase")
+	}
+
+	return bytes.TrimRight(b, "\r\n"), nil
+}
+
+// EnvKeySource loads the keys from PEM values, which may also be base64 encoded
+// The public key is derived from the private key if empty.
+// Retired public keys are concatenated PEM blocks.
+type EnvKeySource struct {
+	Algorithm         string
+	PrivateKey        string
+	PublicKey         string
+	RetiredPublicKeys string
+	Passphrase        Passphrase
+}
+
+// Load implements KeySource
+func (s EnvKeySource) Load() (*KeyRing, error) {
+	passphrase, err := s.Passphrase.load()
+	if err != nil {
+		return nil, err
+	}
+	privKeyBytes, err := decodeEnvPEM(s.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "private key")
+	}
+	pubKeyBytes, err := decodeEnvPEM(s.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "public key")
+	}
+	retiredPubKeyBytes, err := decodeEnvPEM(s.RetiredPublicKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "retired public keys")
+	}
+
+	return newKeyRingFromPEM(s.Algorithm, passphrase, privKeyBytes, pubKeyBytes, splitPEM(retiredPubKeyBytes)...)
+}
+
+// FileKeySource loads the keys from PEM files
+// The public key is derived from the private key if the path is empty.
+type FileKeySource struct {
+	Algorithm             string
+	PrivateKeyPath        string
+	PublicKeyPath         string
+	RetiredPublicKeyPaths []string
+	Passphrase            Passphrase
+}
+
+// Load implements KeySource
+func (s FileKeySource) Load() (*KeyRing, error) {
+	passphrase, err := s.Passphrase.load()
+	if err != nil {
+		return nil, err
+	}
+	privKeyBytes, err := readKeyFile(s.PrivateKeyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "private key")
+	}
+
+	var pubKeyBytes []byte
+	if s.PublicKeyPath != "" {
+		if pubKeyBytes, err = readKeyFile(s.PublicKeyPath); err != nil {
+			return nil, errors.Wrap(err, "public key")
+		}
+	}
+
+	retiredPubKeyBytes := make([][]byte, 0, len(s.RetiredPublicKeyPaths))
+	for _, it := range s.RetiredPublicKeyPaths {
+		b, err := readKeyFile(it)
+		if err != nil {
+			return nil, errors.Wrap(err, "retired public key")
+		}
+		retiredPubKeyBytes = append(retiredPubKeyBytes, b)
+	}
+
+	return newKeyRingFromPEM(s.Algorithm, passphrase, privKeyBytes, pubKeyBytes, retiredPubKeyBytes...)
+}
+
+// DirKeySource loads the keys from a directory of PEM files
+// The directory must contain exactly one private key, which is the active signing key.
+// All the other public keys in the directory are retired keys.
+type DirKeySource struct {
+	Algorithm  string
+	Dir        string
+	Passphrase Passphrase
+}
+
+// Load implements KeySource
+func (s DirKeySource) Load() (*KeyRing, error) {
+	dir := projectpath.Abs(s.Dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "key dir")
+	}
+
+	var privKeyBytes []byte
+	var pubKeyBytes [][]byte
+	for _, it := range entries {
+		if it.IsDir() || strings.HasPrefix(it.Name(), ".") {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, it.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "key dir")
+		}
+
+		block, _ := pem.Decode(b)
+		switch {
+		case block == nil:
+			continue
+		case strings.Contains(block.Type, "PRIVATE KEY"):
+			if privKeyBytes != nil {
+				return nil, errors.Errorf("key dir: more than one private key in %s", s.Dir)
+			}
+			privKeyBytes = b
+		default:
+			pubKeyBytes = append(pubKeyBytes, b)
+		}
+	}
+	if privKeyBytes == nil {
+		return nil, errors.Errorf("key dir: no private key in %s", s.Dir)
+	}
+
+	passphrase, err := s.Passphrase.load()
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := newKeyFromPEM(s.Algorithm, passphrase, privKeyBytes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	retired := make([]Key, 0, len(pubKeyBytes))
+	for _, it := range pubKeyBytes {
+		key, err := newRetiredKeyFromPEM(it)
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip the public key of the active key pair
+		if key.ID == active.ID {
+			continue
+		}
+		retired = append(retired, key)
+	}
+
+	return NewKeyRing(active, retired...)
+}
+
+// PublicKeySource loads verification-only keys from PEM values and files
+// It is used by replicas that verify tokens but must not hold the private key.
+type PublicKeySource struct {
+	// PublicKeys are concatenated PEM blocks, which may also be base64 encoded
+	PublicKeys     []string
+	PublicKeyPaths []string
+}
+
+// Load implements KeySource
+func (s PublicKeySource) Load() (*KeyRing, error) {
+	var pubKeyBytes [][]byte
+	for _, it := range s.PublicKeys {
+		b, err := decodeEnvPEM(it)
+		if err != nil {
+			return nil, errors.Wrap(err, "public key")
+		}
+		pubKeyBytes = append(pubKeyBytes, splitPEM(b)...)
+	}
+	for _, it := range s.PublicKeyPaths {
+		b, err := readKeyFile(it)
+		if err != nil {
+			return nil, errors.Wrap(err, "public key")
+		}
+		pubKeyBytes = append(pubKeyBytes, b)
+	}
+
+	keys := make([]Key, 0, len(pubKeyBytes))
+	for _, it := range pubKeyBytes {
+		key, err := newRetiredKeyFromPEM(it)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	k, err := NewVerificationKeyRing(keys...)
+	if err != nil {
+		return nil, errors.Wrap(err, "key ring")
+	}
+
+	return k, nil
+}
+
+// newKeyRingFromPEM creates a key ring from PEM encoded keys
+func newKeyRingFromPEM(alg string, passphrase, privKeyBytes, pubKeyBytes []byte, retiredPubKeyBytes ...[]byte) (*KeyRing, error) {
+	active, err := newKeyFromPEM(alg, passphrase, privKeyBytes, pubKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	retired := make([]Key, 0, len(retiredPubKeyBytes))
+	for _, it := range retiredPubKeyBytes {
+		key, err := newRetiredKeyFromPEM(it)
+		if err != nil {
+			return nil, err
+		}
+		retired = append(retired, key)
+	}
+
+	k, err := NewKeyRing(active, retired...)
+	if err != nil {
+		return nil, errors.Wrap(err, "key ring")
+	}
+
+	return k, nil
+}
+
+// newKeyFromPEM creates the signing key, deriving the public key from the private key if not provided
+func newKeyFromPEM(alg string, passphrase, privKeyBytes, pubKeyBytes []byte) (Key, error) {
+	signKey, err := ParsePrivateKeyFromPEMWithPassphrase(privKeyBytes, passphrase)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "private key")
+	}
+
+	verifyKey := signKey.Public()
+	if len(pubKeyBytes) > 0 {
+		if verifyKey, err = ParsePublicKeyFromPEM(pubKeyBytes); err != nil {
+			return Key{}, errors.Wrap(err, "public key")
+		}
+	}
+
+	key, err := NewKey(alg, signKey, verifyKey)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "signing key")
+	}
+
+	return key, nil
+}
+
+func newRetiredKeyFromPEM(b []byte) (Key, error) {
+	pubKey, err := ParsePublicKeyFromPEM(b)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "retired public key")
+	}
+
+	key, err := NewKey("", nil, pubKey)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "retired public key")
+	}
+
+	return key, nil
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	return os.ReadFile(projectpath.Abs(path))
+}
+
+// decodeEnvPEM returns the PEM bytes of an env var value that is either PEM or base64 encoded PEM
+func decodeEnvPEM(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if strings.Contains(value, "-----BEGIN") {
+		// Some secret managers escape the new lines
+		return []byte(strings.ReplaceAll(value, `\n`, "\n")), nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "value is neither PEM nor base64 encoded PEM")
+	}
+
+	return b, nil
+}
+
+// splitPEM splits concatenated PEM blocks
+func splitPEM(b []byte) [][]byte {
+	var result [][]byte
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(bytes.TrimSpace(b))
+		if block == nil {
+			return result
+		}
+		result = append(result, pem.EncodeToMemory(block))
+	}
+}
@@ -0,0 +1,503 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvKeySource_Load(t *testing.T) {
+	t.Parallel()
+
+	active := newTestKeyWithAlg(t, "ES256")
+	retired1 := newTestKey(t)
+	retired2 := newTestKeyWithAlg(t, "EdDSA")
+	privPEM := string(encodeTestPrivateKeyPEM(t, active))
+	pubPEM := string(encodeTestPublicKeyPEM(t, active))
+	retiredPEM := string(encodeTestPublicKeyPEM(t, retired1)) + string(encodeTestPublicKeyPEM(t, retired2))
+
+	testCases := []struct {
+		desc     string
+		given    EnvKeySource
+		expected []string
+	}{
+		{
+			desc:     "raw PEM",
+			given:    EnvKeySource{PrivateKey: privPEM, PublicKey: pubPEM},
+			expected: []string{active.ID},
+		},
+		{
+			desc:     "escaped new lines",
+			given:    EnvKeySource{PrivateKey: strings.ReplaceAll(privPEM, "\n", `\n`)},
+			expected: []string{active.ID},
+		},
+		{
+			desc:     "base64 encoded PEM",
+			given:    EnvKeySource{PrivateKey: base64.StdEncoding.EncodeToString([]byte(privPEM))},
+			expected: []string{active.ID},
+		},
+		{
+			desc: "retired public keys",
+			given: EnvKeySource{
+				Algorithm:         "ES256",
+				PrivateKey:        privPEM,
+				RetiredPublicKeys: base64.StdEncoding.EncodeToString([]byte(retiredPEM)),
+			},
+			expected: []string{active.ID, retired1.ID, retired2.ID},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			actual, err := tc.given.Load()
+
+			// Then:
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, keyIDs(actual))
+			assert.Equal(t, active.PrivateKey, actual.SigningKey().PrivateKey)
+		})
+	}
+}
+
+func TestEnvKeySource_Load_Error(t *testing.T) {
+	t.Parallel()
+
+	key := newTestKey(t)
+
+	testCases := []struct {
+		desc  string
+		given EnvKeySource
+	}{
+		{desc: "missing private key", given: EnvKeySource{}},
+		{desc: "not PEM nor base64", given: EnvKeySource{PrivateKey: "not a key"}},
+		{desc: "invalid public key", given: EnvKeySource{PrivateKey: string(encodeTestPrivateKeyPEM(t, key)), PublicKey: "INVALID"}},
+		{desc: "algorithm mismatch", given: EnvKeySource{Algorithm: "EdDSA", PrivateKey: string(encodeTestPrivateKeyPEM(t, key))}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			actual, err := tc.given.Load()
+
+			// Then:
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
+
+func TestFileKeySource_Load(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	dir := t.TempDir()
+	active := newTestKey(t)
+	retired := newTestKeyWithAlg(t, "ES384")
+	privPath := writeTestFile(t, dir, "jwt.rsa", encodeTestPrivateKeyPEM(t, active))
+	pubPath := writeTestFile(t, dir, "jwt.rsa.pub", encodeTestPublicKeyPEM(t, active))
+	retiredPath := writeTestFile(t, dir, "retired.pub", encodeTestPublicKeyPEM(t, retired))
+
+	// When:
+	actual, err := FileKeySource{
+		PrivateKeyPath:        privPath,
+		PublicKeyPath:         pubPath,
+		RetiredPublicKeyPaths: []string{retiredPath},
+	}.Load()
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, []string{active.ID, retired.ID}, keyIDs(actual))
+
+	// When: public key is derived from the private key
+	actual, err = FileKeySource{PrivateKeyPath: privPath}.Load()
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, []string{active.ID}, keyIDs(actual))
+
+	// When: missing file
+	_, err = FileKeySource{PrivateKeyPath: filepath.Join(dir, "missing")}.Load()
+
+	// Then:
+	assert.Error(t, err)
+}
+
+func TestFileKeySource_Load_Passphrase(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	dir := t.TempDir()
+	privPath := writeTestFile(t, dir, "jwt.p8", readTestData(t, "rsa_aes256_sha256.p8"))
+	passphrasePath := writeTestFile(t, dir, "passphrase", []byte("passphrase\n"))
+
+	testCases := []struct {
+		desc       string
+		passphrase Passphrase
+		expectErr  bool
+	}{
+		{desc: "passphrase value", passphrase: Passphrase{Value: "passphrase"}},
+		{desc: "passphrase file", passphrase: Passphrase{Path: passphrasePath}},
+		{desc: "incorrect passphrase", passphrase: Passphrase{Value: "incorrect"}, expectErr: true},
+		{desc: "missing passphrase file", passphrase: Passphrase{Path: filepath.Join(dir, "missing")}, expectErr: true},
+		{desc: "no passphrase", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			actual, err := FileKeySource{PrivateKeyPath: privPath, Passphrase: tc.passphrase}.Load()
+
+			// Then:
+			if tc.expectErr {
+				assert.Error(t, err)
+				assert.Nil(t, actual)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, actual.Keys(), 1)
+		})
+	}
+}
+
+func TestPublicKeySource_Load(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	dir := t.TempDir()
+	active := newTestKey(t)
+	retired1 := newTestKeyWithAlg(t, "ES256")
+	retired2 := newTestKeyWithAlg(t, "EdDSA")
+	retiredPath := writeTestFile(t, dir, "retired.pub", encodeTestPublicKeyPEM(t, retired2))
+
+	// When:
+	actual, err := PublicKeySource{
+		PublicKeys: []string{
+			string(encodeTestPublicKeyPEM(t, active)),
+			base64.StdEncoding.EncodeToString(encodeTestPublicKeyPEM(t, retired1)),
+		},
+		PublicKeyPaths: []string{retiredPath},
+	}.Load()
+
+	// Then:
+	require.NoError(t, err)
+	assert.False(t, actual.CanSign())
+	assert.Equal(t, []string{active.ID, retired1.ID, retired2.ID}, keyIDs(actual))
+
+	// When: no public keys
+	_, err = PublicKeySource{PublicKeys: []string{""}}.Load()
+
+	// Then:
+	assert.Error(t, err)
+}
+
+func TestDirKeySource_Load(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	dir := t.TempDir()
+	active := newTestKeyWithAlg(t, "EdDSA")
+	retired := newTestKey(t)
+	writeTestFile(t, dir, "active.key", encodeTestPrivateKeyPEM(t, active))
+	writeTestFile(t, dir, "active.pub", encodeTestPublicKeyPEM(t, active))
+	writeTestFile(t, dir, "retired.pub", encodeTestPublicKeyPEM(t, retired))
+	writeTestFile(t, dir, "README", []byte("not a key"))
+	writeTestFile(t, dir, ".hidden", []byte("INVALID"))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0o700))
+
+	// When:
+	actual, err := DirKeySource{Dir: dir}.Load()
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, []string{active.ID, retired.ID}, keyIDs(actual))
+	assert.Equal(t, "EdDSA", actual.SigningKey().Method.Alg())
+}
+
+func TestDirKeySource_Load_Error(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc  string
+		files map[string][]byte
+	}{
+		{
+			desc:  "no private key",
+			files: map[string][]byte{"retired.pub": encodeTestPublicKeyPEM(t, newTestKey(t))},
+		},
+		{
+			desc: "more than one private key",
+			files: map[string][]byte{
+				"a.key": encodeTestPrivateKeyPEM(t, newTestKey(t)),
+				"b.key": encodeTestPrivateKeyPEM(t, newTestKey(t)),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			dir := t.TempDir()
+			for name, b := range tc.files {
+				writeTestFile(t, dir, name, b)
+			}
+
+			// When:
+			_, err := DirKeySource{Dir: dir}.Load()
+
+			// Then:
+			assert.Error(t, err)
+		})
+	}
+
+	// When: missing dir
+	_, err := DirKeySource{Dir: filepath.Join(t.TempDir(), "missing")}.Load()
+
+	// Then:
+	assert.Error(t, err)
+}
+
+func TestKeySourceFromEnv(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		env      map[string]string
+		expected KeySource
+	}{
+		{
+			desc: "dir",
+			env: map[string]string{
+				"JWT_KEY_DIR":          "keys",
+				"JWT_PRIVATE_KEY":      "PEM",
+				"JWT_PRIVATE_KEY_PATH": "jwt.rsa",
+				"JWT_SIGNING_ALG":      "ES256",
+			},
+			expected: DirKeySource{Algorithm: "ES256", Dir: "keys"},
+		},
+		{
+			desc: "env",
+			env: map[string]string{
+				"JWT_PRIVATE_KEY":         "PEM",
+				"JWT_PUBLIC_KEY":          "PUB",
+				"JWT_RETIRED_PUBLIC_KEYS": "RETIRED",
+				"JWT_PRIVATE_KEY_PATH":    "jwt.rsa",
+			},
+			expected: EnvKeySource{PrivateKey: "PEM", PublicKey: "PUB", RetiredPublicKeys: "RETIRED"},
+		},
+		{
+			desc: "file",
+			env: map[string]string{
+				"JWT_PRIVATE_KEY_PATH":         "jwt.rsa",
+				"JWT_PUBLIC_KEY_PATH":          "jwt.rsa.pub",
+				"JWT_RETIRED_PUBLIC_KEY_PATHS": "a.pub, ,b.pub",
+			},
+			expected: FileKeySource{
+				PrivateKeyPath:        "jwt.rsa",
+				PublicKeyPath:         "jwt.rsa.pub",
+				RetiredPublicKeyPaths: []string{"a.pub", "b.pub"},
+			},
+		},
+		{
+			desc: "passphrase",
+			env: map[string]string{
+				"JWT_PRIVATE_KEY_PATH":            "jwt.p8",
+				"JWT_PRIVATE_KEY_PASSPHRASE_PATH": "passphrase",
+			},
+			expected: FileKeySource{
+				PrivateKeyPath: "jwt.p8",
+				Passphrase:     Passphrase{Path: "passphrase"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Given:
+			t.Setenv("JWT_SIGNER_URL", "")
+			for _, it := range []string{"JWT_KEY_DIR", "JWT_SIGNING_ALG", "JWT_PRIVATE_KEY", "JWT_PUBLIC_KEY",
+				"JWT_RETIRED_PUBLIC_KEYS", "JWT_PRIVATE_KEY_PATH", "JWT_PUBLIC_KEY_PATH", "JWT_RETIRED_PUBLIC_KEY_PATHS",
+				"JWT_PRIVATE_KEY_PASSPHRASE", "JWT_PRIVATE_KEY_PASSPHRASE_PATH"} {
+				t.Setenv(it, tc.env[it])
+			}
+
+			// When:
+			actual, err := KeySourceFromEnv()
+
+			// Then:
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestKeySourceFromEnv_Signer(t *testing.T) {
+	// Given:
+	t.Setenv("JWT_SIGNER_URL", "unix:///run/signerd.sock")
+	t.Setenv("JWT_PRIVATE_KEY_PATH", "jwt.rsa")
+
+	// When:
+	actual, err := KeySourceFromEnv()
+
+	// Then:
+	require.NoError(t, err)
+	require.IsType(t, RemoteKeySource{}, actual)
+	assert.Equal(t, "unix:///run/signerd.sock", actual.(RemoteKeySource).URL)
+}
+
+func TestKeySourceFromEnv_Error(t *testing.T) {
+	testCases := []struct {
+		desc string
+		env  map[string]string
+	}{
+		{desc: "no key source", env: map[string]string{}},
+		{
+			desc: "both passphrase value and file",
+			env: map[string]string{
+				"JWT_PRIVATE_KEY_PATH":            "jwt.p8",
+				"JWT_PRIVATE_KEY_PASSPHRASE":      "passphrase",
+				"JWT_PRIVATE_KEY_PASSPHRASE_PATH": "passphrase",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Given:
+			for _, it := range []string{"JWT_SIGNER_URL", "JWT_KEY_DIR", "JWT_PRIVATE_KEY", "JWT_PRIVATE_KEY_PATH",
+				"JWT_PRIVATE_KEY_PASSPHRASE", "JWT_PRIVATE_KEY_PASSPHRASE_PATH"} {
+				t.Setenv(it, tc.env[it])
+			}
+
+			// When:
+			actual, err := KeySourceFromEnv()
+
+			// Then:
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
+
+func TestVerifyOnlyKeySourceFromEnv(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		env       map[string]string
+		expected  KeySource
+		expectErr bool
+	}{
+		{
+			desc:     "jwks",
+			env:      map[string]string{"JWT_JWKS_URL": "http://issuer/.well-known/jwks.json", "JWT_PUBLIC_KEY": "PUB"},
+			expected: JWKSKeySource{URL: "http://issuer/.well-known/jwks.json"},
+		},
+		{
+			desc: "public keys",
+			env: map[string]string{
+				"JWT_PUBLIC_KEY":               "PUB",
+				"JWT_RETIRED_PUBLIC_KEYS":      "RETIRED",
+				"JWT_PUBLIC_KEY_PATH":          "jwt.rsa.pub",
+				"JWT_RETIRED_PUBLIC_KEY_PATHS": "a.pub,b.pub",
+			},
+			expected: PublicKeySource{
+				PublicKeys:     []string{"PUB", "RETIRED"},
+				PublicKeyPaths: []string{"jwt.rsa.pub", "a.pub", "b.pub"},
+			},
+		},
+		{
+			desc:      "private key",
+			env:       map[string]string{"JWT_PRIVATE_KEY_PATH": "jwt.rsa", "JWT_PUBLIC_KEY_PATH": "jwt.rsa.pub"},
+			expectErr: true,
+		},
+		{
+			desc:      "key rotation",
+			env:       map[string]string{"JWT_KEY_ROTATION_INTERVAL": "24h", "JWT_JWKS_URL": "http://issuer"},
+			expectErr: true,
+		},
+		{
+			desc:      "no public keys",
+			env:       map[string]string{},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Given:
+			for _, it := range []string{"JWT_SIGNER_URL", "JWT_KEY_DIR", "JWT_PRIVATE_KEY", "JWT_PRIVATE_KEY_PATH", "JWT_KEY_ROTATION_INTERVAL",
+				"JWT_JWKS_URL", "JWT_PUBLIC_KEY", "JWT_RETIRED_PUBLIC_KEYS", "JWT_PUBLIC_KEY_PATH", "JWT_RETIRED_PUBLIC_KEY_PATHS"} {
+				t.Setenv(it, tc.env[it])
+			}
+
+			// When:
+			actual, err := VerifyOnlyKeySourceFromEnv()
+
+			// Then:
+			if tc.expectErr {
+				assert.Error(t, err)
+				assert.Nil(t, actual)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestVerifyOnlyFromEnv(t *testing.T) {
+	testCases := []struct {
+		given     string
+		expected  bool
+		expectErr bool
+	}{
+		{given: "", expected: false},
+		{given: "true", expected: true},
+		{given: "false", expected: false},
+		{given: "yes", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.given, func(t *testing.T) {
+			// Given:
+			t.Setenv("JWT_VERIFY_ONLY", tc.given)
+
+			// When:
+			actual, err := VerifyOnlyFromEnv()
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func encodeTestPrivateKeyPEM(t *testing.T, k Key) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: marshalTestPKCS8(t, k)})
+}
+
+func encodeTestPublicKeyPEM(t *testing.T, k Key) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: marshalTestPKIX(t, k)})
+}
+
+func writeTestFile(t *testing.T, dir, name string, b []byte) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+
+	return path
+}
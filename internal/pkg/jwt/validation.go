@@ -0,0 +1,145 @@
+package jwt
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"github.com/severedsea/jwt-server/internal/pkg/stringsx"
+	"golang.org/x/exp/slices"
+)
+
+// Registered claims that can be required by the ValidationPolicy
+const (
+	ClaimExpiresAt = "exp"
+	ClaimIssuedAt  = "iat"
+	ClaimNotBefore = "nbf"
+	ClaimSubject   = "sub"
+	ClaimID        = "jti"
+	ClaimAudience  = "aud"
+)
+
+var registeredClaimNames = []string{ClaimExpiresAt, ClaimIssuedAt, ClaimNotBefore, ClaimSubject, ClaimID, ClaimAudience}
+
+// ValidationPolicy is the validation of the registered claims of the parsed tokens
+// It applies to any claims type, and replaces the validation done by its Valid method.
+type ValidationPolicy struct {
+	// Issuers are the accepted `iss` values, only Issuer is accepted if empty
+	Issuers []string
+	// RequiredClaims are the registered claims that must be present, e.g. ClaimExpiresAt
+	RequiredClaims []string
+	// Leeway is the accepted clock skew when validating `exp`, `nbf` and `iat`
+	Leeway time.Duration
+	// MaxAge is the maximum age of the token according to `iat`, which is required if set
+	MaxAge time.Duration
+}
+
+// DefaultValidationPolicy returns the policy that only accepts tokens issued by Issuer
+func DefaultValidationPolicy() ValidationPolicy {
+	return ValidationPolicy{Issuers: []string{Issuer}}
+}
+
+// ValidationPolicyFromEnv returns the validation policy configured with the env vars, which are all optional
+//   - JWT_ISSUERS: comma-separated accepted issuers
+//   - JWT_REQUIRED_CLAIMS: comma-separated registered claims that must be present, e.g. `exp,iat,sub,jti`
+//   - JWT_LEEWAY: accepted clock skew, e.g. `30s`
+//   - JWT_MAX_TOKEN_AGE: maximum age of the tokens, e.g. `1h`
+func ValidationPolicyFromEnv() (ValidationPolicy, error) {
+	p := DefaultValidationPolicy()
+	if v := stringsx.SplitList(os.Getenv("JWT_ISSUERS")); len(v) > 0 {
+		p.Issuers = v
+	}
+
+	p.RequiredClaims = stringsx.SplitList(os.Getenv("JWT_REQUIRED_CLAIMS"))
+	for _, it := range p.RequiredClaims {
+		if !slices.Contains(registeredClaimNames, it) {
+			return ValidationPolicy{}, errors.Errorf("JWT_REQUIRED_CLAIMS: unknown claim %q, must be one of %s", it, strings.Join(registeredClaimNames, ", "))
+		}
+	}
+
+	var err error
+	if p.Leeway, err = durationFromEnv("JWT_LEEWAY"); err != nil {
+		return ValidationPolicy{}, err
+	}
+	if p.MaxAge, err = durationFromEnv("JWT_MAX_TOKEN_AGE"); err != nil {
+		return ValidationPolicy{}, err
+	}
+
+	return p, nil
+}
+
+// validate validates the registered claims at the time provided
+func (p ValidationPolicy) validate(c jwt.RegisteredClaims, now time.Time) error {
+	for _, it := range p.RequiredClaims {
+		if !hasClaim(c, it) {
+			return errors.Errorf("missing %s claim", it)
+		}
+	}
+
+	issuers := p.Issuers
+	if len(issuers) == 0 {
+		issuers = []string{Issuer}
+	}
+	if !slices.Contains(issuers, c.Issuer) {
+		return errors.Errorf("unexpected issuer %q", c.Issuer)
+	}
+
+	if c.ExpiresAt != nil && !now.Before(c.ExpiresAt.Add(p.Leeway)) {
+		return errors.New("token is expired")
+	}
+	if c.NotBefore != nil && now.Add(p.Leeway).Before(c.NotBefore.Time) {
+		return errors.New("token is not valid yet")
+	}
+	if c.IssuedAt != nil && now.Add(p.Leeway).Before(c.IssuedAt.Time) {
+		return errors.New("token is issued in the future")
+	}
+
+	if p.MaxAge > 0 {
+		if c.IssuedAt == nil {
+			return errors.New("missing iat claim to check the token age")
+		}
+		if now.Sub(c.IssuedAt.Time) > p.MaxAge+p.Leeway {
+			return errors.New("token is too old")
+		}
+	}
+
+	return nil
+}
+
+func hasClaim(c jwt.RegisteredClaims, name string) bool {
+	switch name {
+	case ClaimExpiresAt:
+		return c.ExpiresAt != nil
+	case ClaimIssuedAt:
+		return c.IssuedAt != nil
+	case ClaimNotBefore:
+		return c.NotBefore != nil
+	case ClaimSubject:
+		return c.Subject != ""
+	case ClaimID:
+		return c.ID != ""
+	case ClaimAudience:
+		return len(c.Audience) > 0
+	}
+
+	return false
+}
+
+func durationFromEnv(key string) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.Wrap(err, key)
+	}
+	if d < 0 {
+		return 0, errors.Errorf("%s must not be negative", key)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,222 @@
+package jwt
+
+import (
+	"context"
+
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationPolicy_Validate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	at := func(d time.Duration) *jwt.NumericDate {
+		return jwt.NewNumericDate(now.Add(d))
+	}
+
+	testCases := []struct {
+		desc     string
+		policy   ValidationPolicy
+		given    jwt.RegisteredClaims
+		expected bool
+	}{
+		{
+			desc:     "default",
+			policy:   DefaultValidationPolicy(),
+			given:    jwt.RegisteredClaims{Issuer: Issuer, ExpiresAt: at(time.Minute), IssuedAt: at(-time.Minute)},
+			expected: true,
+		},
+		{
+			desc:   "unexpected issuer",
+			policy: DefaultValidationPolicy(),
+			given:  jwt.RegisteredClaims{Issuer: "another"},
+		},
+		{
+			desc:   "no issuer",
+			policy: ValidationPolicy{},
+			given:  jwt.RegisteredClaims{},
+		},
+		{
+			desc:     "accepted issuer",
+			policy:   ValidationPolicy{Issuers: []string{Issuer, "another"}},
+			given:    jwt.RegisteredClaims{Issuer: "another"},
+			expected: true,
+		},
+		{
+			desc:     "required claims",
+			policy:   ValidationPolicy{RequiredClaims: []string{ClaimExpiresAt, ClaimSubject, ClaimID}},
+			given:    jwt.RegisteredClaims{Issuer: Issuer, ExpiresAt: at(time.Minute), Subject: "sub", ID: "id"},
+			expected: true,
+		},
+		{
+			desc:   "missing required claim",
+			policy: ValidationPolicy{RequiredClaims: []string{ClaimExpiresAt, ClaimSubject, ClaimID}},
+			given:  jwt.RegisteredClaims{Issuer: Issuer, ExpiresAt: at(time.Minute), Subject: "sub"},
+		},
+		{
+			desc:   "unknown required claim",
+			policy: ValidationPolicy{RequiredClaims: []string{"unknown"}},
+			given:  jwt.RegisteredClaims{Issuer: Issuer},
+		},
+		{
+			desc:   "expired",
+			policy: DefaultValidationPolicy(),
+			given:  jwt.RegisteredClaims{Issuer: Issuer, ExpiresAt: at(-time.Second)},
+		},
+		{
+			desc:     "expired within leeway",
+			policy:   ValidationPolicy{Leeway: time.Minute},
+			given:    jwt.RegisteredClaims{Issuer: Issuer, ExpiresAt: at(-time.Second)},
+			expected: true,
+		},
+		{
+			desc:   "not valid yet",
+			policy: DefaultValidationPolicy(),
+			given:  jwt.RegisteredClaims{Issuer: Issuer, NotBefore: at(time.Second)},
+		},
+		{
+			desc:     "not valid yet within leeway",
+			policy:   ValidationPolicy{Leeway: time.Minute},
+			given:    jwt.RegisteredClaims{Issuer: Issuer, NotBefore: at(time.Second)},
+			expected: true,
+		},
+		{
+			desc:   "issued in the future",
+			policy: DefaultValidationPolicy(),
+			given:  jwt.RegisteredClaims{Issuer: Issuer, IssuedAt: at(time.Second)},
+		},
+		{
+			desc:     "issued in the future within leeway",
+			policy:   ValidationPolicy{Leeway: time.Minute},
+			given:    jwt.RegisteredClaims{Issuer: Issuer, IssuedAt: at(time.Second)},
+			expected: true,
+		},
+		{
+			desc:     "max age",
+			policy:   ValidationPolicy{MaxAge: time.Hour},
+			given:    jwt.RegisteredClaims{Issuer: Issuer, IssuedAt: at(-time.Minute)},
+			expected: true,
+		},
+		{
+			desc:   "too old",
+			policy: ValidationPolicy{MaxAge: time.Hour},
+			given:  jwt.RegisteredClaims{Issuer: Issuer, IssuedAt: at(-2 * time.Hour)},
+		},
+		{
+			desc:   "max age without issued at",
+			policy: ValidationPolicy{MaxAge: time.Hour},
+			given:  jwt.RegisteredClaims{Issuer: Issuer},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			err := tc.policy.validate(tc.given, now)
+
+			// Then:
+			assert.Equal(t, tc.expected, err == nil, err)
+		})
+	}
+}
+
+func TestVerifier_WithPolicy(t *testing.T) {
+	t.Parallel()
+
+	// Given: claims type embedding jwt.RegisteredClaims
+	type customClaims struct {
+		jwt.RegisteredClaims
+		Scope string `json:"scope"`
+	}
+	keys := NewKeyStore(newTestKeyRing(t))
+	signer, err := NewSigner(keys)
+	require.NoError(t, err)
+	verifier, err := NewVerifier(keys)
+	require.NoError(t, err)
+
+	// When: issued by another issuer
+	tokenString, err := signer.Sign(context.Background(), customClaims{RegisteredClaims: jwt.RegisteredClaims{Issuer: "another", Subject: "sub"}})
+	require.NoError(t, err)
+
+	// Then:
+	assert.Equal(t, ErrInvalidToken, verifier.Parse(tokenString, &customClaims{}))
+	assert.NoError(t, verifier.WithPolicy(ValidationPolicy{Issuers: []string{"another"}}).Parse(tokenString, &customClaims{}))
+
+	// When: expired a few seconds ago
+	tokenString, err = signer.Sign(context.Background(), customClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    Issuer,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-5 * time.Second)),
+	}})
+	require.NoError(t, err)
+
+	// Then:
+	assert.Equal(t, ErrInvalidToken, verifier.Parse(tokenString, &customClaims{}))
+	assert.NoError(t, verifier.WithPolicy(ValidationPolicy{Leeway: time.Minute}).Parse(tokenString, &customClaims{}))
+}
+
+func TestValidationPolicyFromEnv(t *testing.T) {
+	// Given:
+	t.Setenv("JWT_ISSUERS", "jwt-server, another")
+	t.Setenv("JWT_REQUIRED_CLAIMS", "exp,iat,sub,jti")
+	t.Setenv("JWT_LEEWAY", "30s")
+	t.Setenv("JWT_MAX_TOKEN_AGE", "1h")
+
+	// When:
+	actual, err := ValidationPolicyFromEnv()
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, ValidationPolicy{
+		Issuers:        []string{Issuer, "another"},
+		RequiredClaims: []string{ClaimExpiresAt, ClaimIssuedAt, ClaimSubject, ClaimID},
+		Leeway:         30 * time.Second,
+		MaxAge:         time.Hour,
+	}, actual)
+}
+
+func TestValidationPolicyFromEnv_Default(t *testing.T) {
+	// Given:
+	for _, key := range []string{"JWT_ISSUERS", "JWT_REQUIRED_CLAIMS", "JWT_LEEWAY", "JWT_MAX_TOKEN_AGE"} {
+		t.Setenv(key, "")
+	}
+
+	// When:
+	actual, err := ValidationPolicyFromEnv()
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, DefaultValidationPolicy(), actual)
+}
+
+func TestValidationPolicyFromEnv_Error(t *testing.T) {
+	testCases := []struct {
+		key   string
+		value string
+	}{
+		{key: "JWT_REQUIRED_CLAIMS", value: "exp,unknown"},
+		{key: "JWT_LEEWAY", value: "30"},
+		{key: "JWT_LEEWAY", value: "-1s"},
+		{key: "JWT_MAX_TOKEN_AGE", value: "forever"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			// Given:
+			t.Setenv(tc.key, tc.value)
+
+			// When:
+			_, err := ValidationPolicyFromEnv()
+
+			// Then:
+			assert.Error(t, err)
+		})
+	}
+}
@@ -5,18 +5,77 @@ import (
 	"strings"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/severedsea/jwt-server/internal/pkg/stringsx"
 )
 
 // New returns a redis client
+// It is a Sentinel client if REDIS_SENTINEL_MASTER is set, a Redis Cluster client if REDIS_CLUSTER_ADDRS is set,
+// and a single node client from the other REDIS_* env vars otherwise.
 func New() (redis.Cmdable, error) {
-	opt, err := redis.ParseURL(redisURLFromEnv())
+	opt, err := universalOptionsFromEnv()
 	if err != nil {
 		return nil, err
 	}
 
-	opt.MaxRetries = 3
+	switch {
+	case opt.MasterName != "":
+		return redis.NewUniversalClient(opt), nil
 
-	return redis.NewClient(opt), nil
+	case len(opt.Addrs) > 0:
+		// The universal client would be a single node client with only one seed address
+		return redis.NewClusterClient(opt.Cluster()), nil
+	}
+
+	single, err := redis.ParseURL(redisURLFromEnv())
+	if err != nil {
+		return nil, err
+	}
+	single.MaxRetries = 3
+	single.TLSConfig = opt.TLSConfig
+
+	return redis.NewClient(single), nil
+}
+
+// universalOptionsFromEnv returns the options of the Sentinel or Redis Cluster client from the REDIS_* env vars
+// It has neither a master name nor addresses if neither is configured, and its TLS config applies to every client.
+func universalOptionsFromEnv() (*redis.UniversalOptions, error) {
+	tlsConfig, err := tlsConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	opt := &redis.UniversalOptions{
+		Username:         os.Getenv("REDIS_USER"),
+		Password:         os.Getenv("REDIS_PWD"),
+		SentinelPassword: os.Getenv("REDIS_SENTINEL_PWD"),
+		MaxRetries:       3,
+		TLSConfig:        tlsConfig,
+	}
+
+	masterName := os.Getenv("REDIS_SENTINEL_MASTER")
+	sentinelAddrs := stringsx.SplitList(os.Getenv("REDIS_SENTINEL_ADDRS"))
+	clusterAddrs := stringsx.SplitList(os.Getenv("REDIS_CLUSTER_ADDRS"))
+
+	switch {
+	case masterName != "" && len(clusterAddrs) > 0:
+		return nil, errors.New("REDIS_SENTINEL_MASTER and REDIS_CLUSTER_ADDRS cannot be both set")
+
+	case masterName != "":
+		if len(sentinelAddrs) == 0 {
+			return nil, errors.New("REDIS_SENTINEL_ADDRS is required with REDIS_SENTINEL_MASTER")
+		}
+		opt.MasterName = masterName
+		opt.Addrs = sentinelAddrs
+
+	case len(sentinelAddrs) > 0:
+		return nil, errors.New("REDIS_SENTINEL_MASTER is required with REDIS_SENTINEL_ADDRS")
+
+	default:
+		opt.Addrs = clusterAddrs
+	}
+
+	return opt, nil
 }
 
 // redisURLFromEnv constructs the redis URL from the REDIS_* env vars
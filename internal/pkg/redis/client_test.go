@@ -95,3 +95,71 @@ func TestRedisURLFromEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestUniversalOptionsFromEnv(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		masterName    string
+		sentinelAddrs string
+		clusterAddrs  string
+		expMasterName string
+		expAddrs      []string
+		expectErr     bool
+	}{
+		{
+			desc: "single node",
+		},
+		{
+			desc:          "sentinel",
+			masterName:    "mymaster",
+			sentinelAddrs: "sentinel-1:26379, sentinel-2:26379,",
+			expMasterName: "mymaster",
+			expAddrs:      []string{"sentinel-1:26379", "sentinel-2:26379"},
+		},
+		{
+			desc:         "cluster",
+			clusterAddrs: "node-1:6379,node-2:6379",
+			expAddrs:     []string{"node-1:6379", "node-2:6379"},
+		},
+		{
+			desc:       "sentinel without addresses",
+			masterName: "mymaster",
+			expectErr:  true,
+		},
+		{
+			desc:          "sentinel without master name",
+			sentinelAddrs: "sentinel-1:26379",
+			expectErr:     true,
+		},
+		{
+			desc:          "sentinel and cluster",
+			masterName:    "mymaster",
+			sentinelAddrs: "sentinel-1:26379",
+			clusterAddrs:  "node-1:6379",
+			expectErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Given:
+			t.Setenv("REDIS_SENTINEL_MASTER", tc.masterName)
+			t.Setenv("REDIS_SENTINEL_ADDRS", tc.sentinelAddrs)
+			t.Setenv("REDIS_CLUSTER_ADDRS", tc.clusterAddrs)
+			t.Setenv("REDIS_USER", "user")
+			t.Setenv("REDIS_PWD", "password")
+
+			// When:
+			actual, err := universalOptionsFromEnv()
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			if !tc.expectErr {
+				assert.Equal(t, tc.expMasterName, actual.MasterName)
+				assert.Equal(t, tc.expAddrs, actual.Addrs)
+				assert.Equal(t, "user", actual.Username)
+				assert.Equal(t, "password", actual.Password)
+			}
+		})
+	}
+}
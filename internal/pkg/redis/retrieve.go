@@ -3,13 +3,45 @@ package redis
 import (
 	"context"
 	"sort"
+	"sync"
 
 	"github.com/go-redis/redis/v8"
 	"golang.org/x/exp/slices"
 )
 
 // RetrieveKeysByPattern returns all keys that match the specified pattern from redis
+// The keys of a Redis Cluster are retrieved from every master, since Scan only iterates the keys of one node.
 func RetrieveKeysByPattern(ctx context.Context, r redis.Cmdable, pattern string, batchSize int64) ([]string, error) {
+	cluster, ok := r.(*redis.ClusterClient)
+	if !ok {
+		return scanKeys(ctx, r, pattern, batchSize)
+	}
+
+	var mu sync.Mutex
+	var allKeys []string
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		keys, err := scanKeys(ctx, master, pattern, batchSize)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		allKeys = append(allKeys, keys...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(allKeys)
+
+	return allKeys, nil
+}
+
+// scanKeys returns all keys that match the specified pattern from one redis node
+func scanKeys(ctx context.Context, r redis.Cmdable, pattern string, batchSize int64) ([]string, error) {
 	var keys, allKeys []string
 	var cursor uint64
 	var err error
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Sentinel(t *testing.T) {
+	// Given: a master monitored by a sentinel
+	ctx := context.Background()
+	master := startRedisServer(t, "")
+	sentinel := startRedisServer(t, "sentinel monitor mymaster 127.0.0.1 "+portOf(master)+" 1\n", "--sentinel")
+
+	t.Setenv("REDIS_SENTINEL_MASTER", "mymaster")
+	t.Setenv("REDIS_SENTINEL_ADDRS", sentinel)
+
+	// When:
+	c, err := New()
+	require.NoError(t, err)
+
+	// Then: the commands go to the master
+	require.NoError(t, c.Set(ctx, "sentinel_key", "value", time.Minute).Err())
+	v, err := redis.NewClient(&redis.Options{Addr: master}).Get(ctx, "sentinel_key").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "value", v)
+}
+
+func TestNew_Cluster(t *testing.T) {
+	// Given: a cluster of three masters
+	ctx := context.Background()
+	var nodes []string
+	for i := 0; i < 3; i++ {
+		nodes = append(nodes, startRedisServer(t, "", "--cluster-enabled", "yes"))
+	}
+	startCluster(t, nodes)
+
+	// Given: only one of them is known
+	t.Setenv("REDIS_CLUSTER_ADDRS", nodes[0])
+
+	// When:
+	c, err := New()
+	require.NoError(t, err)
+
+	// Then: the keys are spread over the cluster
+	var given []string
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("cluster_key_%d", i)
+		require.NoError(t, c.Set(ctx, key, "value", time.Minute).Err())
+		given = append(given, key)
+	}
+	for _, key := range given {
+		v, err := c.Get(ctx, key).Result()
+		require.NoError(t, err)
+		assert.Equal(t, "value", v)
+	}
+
+	// Then: the keys are retrieved from every node
+	actual, err := RetrieveKeysByPattern(ctx, c, "cluster_key_*", 5)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, given, actual)
+}
+
+// startRedisServer starts a redis-server process with the config on a free port and returns its address
+// The test is skipped if redis-server is not installed.
+func startRedisServer(t *testing.T, config string, args ...string) string {
+	t.Helper()
+
+	bin, err := exec.LookPath("redis-server")
+	if err != nil {
+		t.Skip("redis-server is not installed")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	// Sentinel requires a writable config file
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "redis.conf")
+	require.NoError(t, os.WriteFile(conf, []byte(config), 0o600))
+
+	cmd := exec.Command(bin, append([]string{conf, "--port", portOf(addr), "--bind", "127.0.0.1", "--dir", dir, "--save", ""}, args...)...)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	c := redis.NewClient(&redis.Options{Addr: addr})
+	defer c.Close()
+	require.Eventually(t, func() bool {
+		return c.Ping(context.Background()).Err() == nil
+	}, 5*time.Second, 50*time.Millisecond, "redis-server did not start")
+
+	return addr
+}
+
+// startCluster assigns the hash slots evenly to the nodes and waits for them to form the cluster
+func startCluster(t *testing.T, nodes []string) {
+	t.Helper()
+
+	ctx := context.Background()
+	for i, addr := range nodes {
+		c := redis.NewClient(&redis.Options{Addr: addr})
+		defer c.Close()
+
+		first, last := i*16384/len(nodes), (i+1)*16384/len(nodes)-1
+		require.NoError(t, c.ClusterAddSlotsRange(ctx, first, last).Err())
+		if i > 0 {
+			require.NoError(t, c.ClusterMeet(ctx, "127.0.0.1", portOf(nodes[0])).Err())
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		for _, addr := range nodes {
+			c := redis.NewClient(&redis.Options{Addr: addr})
+			info, err := c.ClusterInfo(ctx).Result()
+			c.Close()
+			if err != nil || !strings.Contains(info, "cluster_state:ok") ||
+				!strings.Contains(info, "cluster_known_nodes:"+strconv.Itoa(len(nodes))) {
+				return false
+			}
+		}
+
+		return true
+	}, 10*time.Second, 100*time.Millisecond, "cluster did not start")
+}
+
+// portOf returns the port of the host:port address
+func portOf(addr string) string {
+	_, port, _ := net.SplitHostPort(addr)
+
+	return port
+}
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/projectpath"
+)
+
+// tlsConfigFromEnv returns the TLS config of the redis connection, which is nil unless REDIS_SCHEME is rediss
+//
+//   - REDIS_TLS_CA_PATH: PEM bundle of the CAs that are trusted instead of the system ones
+//   - REDIS_TLS_CERT_PATH and REDIS_TLS_KEY_PATH: PEM client certificate and key for mTLS
+//   - REDIS_TLS_SERVER_NAME: name that the server certificate is verified against, instead of the host
+func tlsConfigFromEnv() (*tls.Config, error) {
+	caPath := os.Getenv("REDIS_TLS_CA_PATH")
+	certPath, keyPath := os.Getenv("REDIS_TLS_CERT_PATH"), os.Getenv("REDIS_TLS_KEY_PATH")
+	serverName := os.Getenv("REDIS_TLS_SERVER_NAME")
+
+	if os.Getenv("REDIS_SCHEME") != "rediss" {
+		if caPath != "" || certPath != "" || keyPath != "" || serverName != "" {
+			return nil, errors.New("REDIS_TLS_* requires REDIS_SCHEME to be rediss")
+		}
+
+		return nil, nil
+	}
+
+	// The server name is taken from the address of each node if not configured
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+
+	if caPath != "" {
+		b, err := os.ReadFile(projectpath.Abs(caPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "REDIS_TLS_CA_PATH")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("REDIS_TLS_CA_PATH has no PEM certificate: %s", caPath)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certPath != "" || keyPath != "" {
+		if certPath == "" || keyPath == "" {
+			return nil, errors.New("REDIS_TLS_CERT_PATH and REDIS_TLS_KEY_PATH must be both set")
+		}
+
+		cert, err := tls.LoadX509KeyPair(projectpath.Abs(certPath), projectpath.Abs(keyPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "REDIS_TLS_CERT_PATH")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
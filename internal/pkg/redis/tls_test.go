@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfigFromEnv(t *testing.T) {
+	certPath, keyPath := writeTestCertificate(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	testCases := []struct {
+		desc       string
+		scheme     string
+		caPath     string
+		certPath   string
+		keyPath    string
+		serverName string
+		expNil     bool
+		expCerts   int
+		expectErr  bool
+	}{
+		{desc: "no tls", scheme: "redis", expNil: true},
+		{desc: "tls", scheme: "rediss"},
+		{desc: "ca", scheme: "rediss", caPath: certPath},
+		{desc: "client certificate", scheme: "rediss", certPath: certPath, keyPath: keyPath, expCerts: 1},
+		{desc: "server name", scheme: "rediss", serverName: "redis.internal"},
+		{desc: "tls options without tls", scheme: "redis", caPath: certPath, expectErr: true},
+		{desc: "ca not found", scheme: "rediss", caPath: filepath.Join(t.TempDir(), "missing.pem"), expectErr: true},
+		{desc: "ca not pem", scheme: "rediss", caPath: notPEM, expectErr: true},
+		{desc: "certificate without key", scheme: "rediss", certPath: certPath, expectErr: true},
+		{desc: "key without certificate", scheme: "rediss", keyPath: keyPath, expectErr: true},
+		{desc: "key not found", scheme: "rediss", certPath: certPath, keyPath: filepath.Join(t.TempDir(), "missing.key"), expectErr: true},
+		{desc: "key not pem", scheme: "rediss", certPath: certPath, keyPath: notPEM, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Given:
+			t.Setenv("REDIS_SCHEME", tc.scheme)
+			t.Setenv("REDIS_TLS_CA_PATH", tc.caPath)
+			t.Setenv("REDIS_TLS_CERT_PATH", tc.certPath)
+			t.Setenv("REDIS_TLS_KEY_PATH", tc.keyPath)
+			t.Setenv("REDIS_TLS_SERVER_NAME", tc.serverName)
+
+			// When:
+			actual, err := tlsConfigFromEnv()
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			if tc.expectErr || tc.expNil {
+				assert.Nil(t, actual)
+				return
+			}
+			require.NotNil(t, actual)
+			assert.Equal(t, tc.serverName, actual.ServerName)
+			assert.Equal(t, tc.caPath != "", actual.RootCAs != nil)
+			assert.Len(t, actual.Certificates, tc.expCerts)
+		})
+	}
+}
+
+func TestNew_TLS(t *testing.T) {
+	// Given: the CA bundle cannot be read
+	t.Setenv("REDIS_SCHEME", "rediss")
+	t.Setenv("REDIS_HOST", "localhost")
+	t.Setenv("REDIS_TLS_CA_PATH", filepath.Join(t.TempDir(), "missing.pem"))
+
+	// When:
+	_, err := New()
+
+	// Then: it fails on startup instead of on the first command
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "REDIS_TLS_CA_PATH")
+}
+
+// writeTestCertificate writes a self-signed certificate and its key as PEM files
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "redis.crt"), filepath.Join(dir, "redis.key")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certPath, keyPath
+}
@@ -0,0 +1,16 @@
+// Package stringsx contains string helpers that are not in the standard library
+package stringsx
+
+import "strings"
+
+// SplitList splits a comma-separated list, trims the values and drops the empty ones
+func SplitList(s string) []string {
+	var result []string
+	for _, it := range strings.Split(s, ",") {
+		if it = strings.TrimSpace(it); it != "" {
+			result = append(result, it)
+		}
+	}
+
+	return result
+}
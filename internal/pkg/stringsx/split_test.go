@@ -0,0 +1,34 @@
+package stringsx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitList(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		given    string
+		expected []string
+	}{
+		{given: ""},
+		{given: " , ,"},
+		{given: "orders", expected: []string{"orders"}},
+		{given: "orders, payments ,,profile", expected: []string{"orders", "payments", "profile"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.given, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			actual := SplitList(tc.given)
+
+			// Then:
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"os"
+	"strings"
+
+	"github.com/severedsea/jwt-server/internal/pkg/stringsx"
+)
+
+// KnownAudiencesFromEnv returns the comma-separated audiences in AUTH_AUDIENCES that tokens may be issued for
+// No audience can be requested at login if it is not configured.
+func KnownAudiencesFromEnv() []string {
+	return stringsx.SplitList(os.Getenv("AUTH_AUDIENCES"))
+}
+
+// AudienceFromEnv returns the audience in AUTH_AUDIENCE that the tokens verified by this deployment must be issued for
+// Tokens are not checked for an audience if it is not configured.
+func AudienceFromEnv() string {
+	return strings.TrimSpace(os.Getenv("AUTH_AUDIENCE"))
+}
+
+// audience returns the `aud` claim for the requested audience
+func audience(aud string) []string {
+	if aud == "" {
+		return nil
+	}
+
+	return []string{aud}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/severedsea/golang-kit/web"
+	"github.com/severedsea/golang-kit/web/middleware"
+)
+
+// Require authorizes the request only if the predicate returns true for the claims
+// It must be used after Middleware, which puts the claims in the context.
+func Require(predicate func(c Claims) bool) middleware.Adapter {
+	return authorize(func(c Claims) *web.Error {
+		if !predicate(c) {
+			return ErrForbidden
+		}
+
+		return nil
+	})
+}
+
+// RequireScope authorizes the request only if all the scopes are granted
+func RequireScope(scopes ...string) middleware.Adapter {
+	return authorize(func(c Claims) *web.Error {
+		for _, it := range scopes {
+			if !c.HasScope(it) {
+				return withDesc(ErrInsufficientScope, fmt.Sprintf("Requires scope: %s", strings.Join(scopes, " ")))
+			}
+		}
+
+		return nil
+	}, scopes...)
+}
+
+// RequireRole authorizes the request only if any of the roles is granted
+func RequireRole(roles ...string) middleware.Adapter {
+	return authorize(func(c Claims) *web.Error {
+		for _, it := range roles {
+			if c.HasRole(it) {
+				return nil
+			}
+		}
+
+		return withDesc(ErrForbidden, fmt.Sprintf("Requires any role: %s", strings.Join(roles, ", ")))
+	})
+}
+
+// authorize responds with the error returned by the check for the claims in the context
+// Denials carry the RFC 6750 insufficient_scope hint with the required scopes, if any.
+func authorize(check func(c Claims) *web.Error, scopes ...string) middleware.Adapter {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			c, err := ClaimsFromContext(ctx)
+			if err != nil {
+				web.RespondJSON(ctx, w, err, nil)
+
+				return
+			}
+
+			if err := check(c); err != nil {
+				web.RespondJSON(ctx, w, err, map[string]string{
+					"WWW-Authenticate": insufficientScopeChallenge(scopes),
+				})
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func insufficientScopeChallenge(scopes []string) string {
+	challenge := `Bearer error="insufficient_scope"`
+	if len(scopes) > 0 {
+		challenge += fmt.Sprintf(`, scope="%s"`, strings.Join(scopes, " "))
+	}
+
+	return challenge
+}
+
+// withDesc returns a copy of the error with the description, as web.NewError modifies the shared error
+func withDesc(err *web.Error, desc string) *web.Error {
+	result := *err
+	result.Desc = desc
+
+	return &result
+}
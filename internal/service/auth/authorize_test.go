@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/severedsea/golang-kit/web"
+	"github.com/severedsea/golang-kit/web/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	claims := Claims{Scope: "orders:read profile", Roles: []string{"support"}}
+
+	testCases := []struct {
+		desc           string
+		given          middleware.Adapter
+		expCode        string
+		expChallenge   string
+		expDescription string
+	}{
+		{desc: "scope", given: RequireScope("orders:read")},
+		{desc: "all scopes", given: RequireScope("orders:read", "profile")},
+		{
+			desc:           "missing scope",
+			given:          RequireScope("orders:read", "orders:write"),
+			expCode:        "insufficient_scope",
+			expChallenge:   `Bearer error="insufficient_scope", scope="orders:read orders:write"`,
+			expDescription: "Requires scope: orders:read orders:write",
+		},
+		{desc: "any role", given: RequireRole("admin", "support")},
+		{
+			desc:           "missing role",
+			given:          RequireRole("admin"),
+			expCode:        "forbidden",
+			expChallenge:   `Bearer error="insufficient_scope"`,
+			expDescription: "Requires any role: admin",
+		},
+		{desc: "predicate", given: Require(func(c Claims) bool { return len(c.Roles) > 0 })},
+		{
+			desc:           "predicate denied",
+			given:          Require(func(c Claims) bool { return c.Subject == "admin" }),
+			expCode:        "forbidden",
+			expChallenge:   `Bearer error="insufficient_scope"`,
+			expDescription: "Forbidden",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			var passed bool
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				passed = true
+			})
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/some/path", nil)
+			r = r.WithContext(setClaimsContext(r.Context(), claims))
+
+			// When:
+			tc.given(handler).ServeHTTP(w, r)
+
+			// Then:
+			if tc.expCode == "" {
+				assert.True(t, passed)
+				assert.Equal(t, http.StatusOK, w.Code)
+				return
+			}
+
+			assert.False(t, passed)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Equal(t, tc.expChallenge, w.Header().Get("WWW-Authenticate"))
+
+			var actual web.Error
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&actual))
+			assert.Equal(t, tc.expCode, actual.Code)
+			assert.Equal(t, tc.expDescription, actual.Desc)
+		})
+	}
+}
+
+func TestAuthorize_MissingContext(t *testing.T) {
+	t.Parallel()
+
+	// Given: authentication middleware was not applied
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/some/path", nil).WithContext(context.Background())
+
+	// When:
+	RequireScope("profile")(http.NotFoundHandler()).ServeHTTP(w, r)
+
+	// Then:
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
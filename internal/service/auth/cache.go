@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/logr"
+	"github.com/severedsea/golang-kit/timex"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// verifyCacheChannel is the redis channel of the invalidations of the verification caches of every replica
+	verifyCacheChannel = "auth_verify_cache_invalidations"
+
+	defaultVerifyCacheTTL = 5 * time.Second
+	maxVerifyCacheTTL     = time.Minute
+)
+
+// PubSub is the redis client that shares the invalidations of the VerifyCache between replicas
+type PubSub interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// VerifyCache keeps the tokens that were verified recently, so that they are not looked up in the store on every request
+// Only positive results are kept, for a short TTL and within a bounded size, the least recently used are evicted first.
+// The results of a session are evicted when it is logged out or its token is revoked, on every replica if the
+// invalidations are shared through redis. The idle timeout of a session is not extended by cached verifications.
+type VerifyCache struct {
+	pubsub PubSub
+	size   int
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	// generation changes on every invalidation, so that a verification that started before is not cached
+	generation uint64
+}
+
+// verifyCacheEntry is a verified token, keyed by the hash of the token
+type verifyCacheEntry struct {
+	tokenHash string
+	subject   string
+	sessionID string
+	tokenID   string
+	expiresAt time.Time
+}
+
+// cacheInvalidation is the message that evicts the verified tokens of the sessions, or of the token ID
+// Every session of the subject is evicted if no session ID is given.
+type cacheInvalidation struct {
+	Subject    string   `json:"subject,omitempty"`
+	SessionIDs []string `json:"session_ids,omitempty"`
+	TokenID    string   `json:"token_id,omitempty"`
+}
+
+// VerifyCacheFromEnv returns the VerifyCache of AUTH_VERIFY_CACHE_SIZE results, which are kept for AUTH_VERIFY_CACHE_TTL
+// It is nil if the size is not configured. The invalidations are shared through redis if the sessions are kept there.
+func VerifyCacheFromEnv(store SessionStore) (*VerifyCache, error) {
+	v := os.Getenv("AUTH_VERIFY_CACHE_SIZE")
+	if v == "" {
+		return nil, nil
+	}
+
+	size, err := strconv.Atoi(v)
+	if err != nil || size < 0 {
+		return nil, errors.Errorf("AUTH_VERIFY_CACHE_SIZE must be a non-negative integer: %s", v)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+
+	ttl := defaultVerifyCacheTTL
+	if v := os.Getenv("AUTH_VERIFY_CACHE_TTL"); v != "" {
+		ttl, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "AUTH_VERIFY_CACHE_TTL")
+		}
+		if ttl <= 0 || ttl > maxVerifyCacheTTL {
+			return nil, errors.Errorf("AUTH_VERIFY_CACHE_TTL must be positive and at most %s: %s", maxVerifyCacheTTL, v)
+		}
+	}
+
+	var pubsub PubSub
+	if rs, ok := store.(RedisStore); ok {
+		pubsub, ok = rs.redis.(PubSub)
+		if !ok {
+			return nil, errors.New("redis client does not support pub/sub")
+		}
+	}
+
+	return NewVerifyCache(pubsub, size, ttl), nil
+}
+
+// NewVerifyCache creates a VerifyCache of at most size results, which are kept for ttl
+// The invalidations are only applied locally if pubsub is nil.
+func NewVerifyCache(pubsub PubSub, size int, ttl time.Duration) *VerifyCache {
+	return &VerifyCache{
+		pubsub:  pubsub,
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// Run applies the invalidations published by every replica until the context is done
+// The cache is cleared whenever the subscription is (re)established, since invalidations may have been missed.
+func (c *VerifyCache) Run(ctx context.Context) {
+	if c == nil || c.pubsub == nil {
+		return
+	}
+	logger := logr.GetLogger(ctx)
+
+	sub := c.pubsub.Subscribe(ctx, verifyCacheChannel)
+	defer sub.Close()
+
+	for {
+		msg, err := sub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Errorf("[auth] Verify cache invalidations not received: %s", err)
+			c.clear()
+			time.Sleep(time.Second)
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			c.clear()
+
+		case *redis.Message:
+			var inv cacheInvalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				logger.Errorf("[auth] Verify cache invalidation not applied: %s", err)
+				c.clear()
+				continue
+			}
+			c.evict(inv)
+		}
+	}
+}
+
+// get checks if the token with the hash was verified, and returns the generation to add its result with otherwise
+func (c *VerifyCache) get(tokenHash string) (bool, uint64) {
+	if c == nil {
+		return false, 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[tokenHash]
+	if !ok {
+		return false, c.generation
+	}
+	if !timex.NowSGT().Before(el.Value.(*verifyCacheEntry).expiresAt) {
+		c.remove(el)
+		return false, c.generation
+	}
+	c.order.MoveToFront(el)
+
+	return true, c.generation
+}
+
+// add keeps the verified token with the hash, unless there was an invalidation since the generation
+// It is kept until the TTL or until the token expires, whichever is first.
+func (c *VerifyCache) add(tokenHash string, claims Claims, generation uint64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	expiresAt := timex.NowSGT().Add(c.ttl)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	if el, ok := c.entries[tokenHash]; ok {
+		c.remove(el)
+	}
+	c.entries[tokenHash] = c.order.PushFront(&verifyCacheEntry{
+		tokenHash: tokenHash,
+		subject:   claims.Subject,
+		sessionID: claims.SessionID,
+		tokenID:   claims.ID,
+		expiresAt: expiresAt,
+	})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// invalidate evicts the verified tokens locally and publishes the invalidation to the other replicas
+// The other replicas keep them until the TTL if it cannot be published, which does not fail the caller.
+func (c *VerifyCache) invalidate(ctx context.Context, inv cacheInvalidation) {
+	if c == nil {
+		return
+	}
+	c.evict(inv)
+
+	if c.pubsub == nil {
+		return
+	}
+
+	b, err := json.Marshal(inv)
+	if err == nil {
+		err = c.pubsub.Publish(ctx, verifyCacheChannel, string(b)).Err()
+	}
+	if err != nil {
+		logr.GetLogger(ctx).Errorf("[auth] Verify cache invalidation not published: %s", err)
+	}
+}
+
+// evict removes the verified tokens that match the invalidation
+// It is linear in the size of the cache, which is fine since invalidations are rare compared to verifications.
+func (c *VerifyCache) evict(inv cacheInvalidation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, el := range c.entries {
+		it := el.Value.(*verifyCacheEntry)
+
+		switch {
+		case inv.TokenID != "":
+			if it.tokenID != inv.TokenID {
+				continue
+			}
+		case it.subject != inv.Subject:
+			continue
+		case len(inv.SessionIDs) > 0 && !slices.Contains(inv.SessionIDs, it.sessionID):
+			continue
+		}
+		c.remove(el)
+	}
+}
+
+// clear removes every verified token
+func (c *VerifyCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+}
+
+// remove removes the verified token of the element
+func (c *VerifyCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*verifyCacheEntry).tokenHash)
+}
@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"github.com/severedsea/jwt-server/internal/pkg/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slices"
+)
+
+func TestVerifyToken_Cache(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	store := &countingStore{SessionStore: NewMemoryStore()}
+	s := newTestService(store, WithVerifyCache(NewVerifyCache(nil, 10, time.Minute)))
+
+	token, err := s.GenerateToken(ctx, LoginRequest{Subject: "sub"})
+	require.NoError(t, err)
+	c, err := s.ParseToken(ctx, token.AccessToken)
+	require.NoError(t, err)
+
+	// When:
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.VerifyToken(ctx, token.AccessToken, c))
+	}
+
+	// Then: the token is only looked up once
+	assert.EqualValues(t, 1, store.gets.Load())
+
+	// When: the session is logged out
+	require.NoError(t, s.Logout(ctx, c))
+
+	// Then: the token is looked up again
+	assert.Equal(t, jwt.ErrInvalidToken, s.VerifyToken(ctx, token.AccessToken, c))
+	assert.EqualValues(t, 2, store.gets.Load())
+}
+
+func TestVerifyToken_CacheRevoked(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	s := newTestService(NewMemoryStore(), WithVerifyCache(NewVerifyCache(nil, 10, time.Minute)))
+
+	token, err := s.GenerateToken(ctx, LoginRequest{Subject: "sub"})
+	require.NoError(t, err)
+	c, err := s.ParseToken(ctx, token.AccessToken)
+	require.NoError(t, err)
+	require.NoError(t, s.VerifyToken(ctx, token.AccessToken, c))
+
+	// When:
+	require.NoError(t, s.RevokeToken(ctx, c))
+
+	// Then:
+	assert.Equal(t, jwt.ErrInvalidToken, s.VerifyToken(ctx, token.AccessToken, c))
+}
+
+func TestVerifyCache(t *testing.T) {
+	t.Parallel()
+
+	claims := func(subject, sessionID, tokenID string) Claims {
+		c := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: subject, ID: tokenID}, SessionID: sessionID}
+		c.ExpiresAt = jwtgo.NewNumericDate(time.Now().Add(time.Hour))
+
+		return c
+	}
+
+	testCases := []struct {
+		desc     string
+		given    cacheInvalidation
+		expected []string
+	}{
+		{desc: "token", given: cacheInvalidation{TokenID: "jti-1"}, expected: []string{"B", "C", "D"}},
+		{desc: "session", given: cacheInvalidation{Subject: "sub", SessionIDs: []string{"laptop"}}, expected: []string{"B", "C", "D"}},
+		{desc: "sessions", given: cacheInvalidation{Subject: "sub", SessionIDs: []string{"laptop", "phone"}}, expected: []string{"C", "D"}},
+		{desc: "subject", given: cacheInvalidation{Subject: "sub"}, expected: []string{"D"}},
+		{desc: "legacy", given: cacheInvalidation{Subject: "sub", SessionIDs: []string{""}}, expected: []string{"A", "B", "D"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			c := NewVerifyCache(nil, 10, time.Minute)
+			given := map[string]Claims{
+				"A": claims("sub", "laptop", "jti-1"),
+				"B": claims("sub", "phone", "jti-2"),
+				"C": claims("sub", "", "jti-3"),
+				"D": claims("another", "laptop", "jti-4"),
+			}
+			for hash, it := range given {
+				_, generation := c.get(hash)
+				c.add(hash, it, generation)
+			}
+
+			// When:
+			c.invalidate(context.Background(), tc.given)
+
+			// Then:
+			for hash := range given {
+				cached, _ := c.get(hash)
+				assert.Equal(t, slices.Contains(tc.expected, hash), cached, hash)
+			}
+		})
+	}
+}
+
+func TestVerifyCache_Bounds(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	c := NewVerifyCache(nil, 2, 200*time.Millisecond)
+	claims := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: "sub", ExpiresAt: jwtgo.NewNumericDate(time.Now().Add(time.Hour))}}
+	for _, hash := range []string{"A", "B"} {
+		_, generation := c.get(hash)
+		c.add(hash, claims, generation)
+	}
+
+	// When: A is used, then C is added
+	cached, _ := c.get("A")
+	require.True(t, cached)
+	_, generation := c.get("C")
+	c.add("C", claims, generation)
+
+	// Then: the least recently used is evicted
+	cached, _ = c.get("B")
+	assert.False(t, cached)
+	cached, _ = c.get("A")
+	assert.True(t, cached)
+
+	// Then: the results expire after the TTL
+	time.Sleep(300 * time.Millisecond)
+	cached, _ = c.get("A")
+	assert.False(t, cached)
+
+	// When: the token expires before the TTL
+	expiring := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: "sub", ExpiresAt: jwtgo.NewNumericDate(time.Now().Add(-time.Second))}}
+	_, generation = c.get("D")
+	c.add("D", expiring, generation)
+
+	// Then:
+	cached, _ = c.get("D")
+	assert.False(t, cached)
+}
+
+func TestVerifyCache_InvalidatedDuringVerification(t *testing.T) {
+	t.Parallel()
+
+	// Given: a verification started before the session was logged out
+	c := NewVerifyCache(nil, 10, time.Minute)
+	claims := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: "sub", ExpiresAt: jwtgo.NewNumericDate(time.Now().Add(time.Hour))}, SessionID: "laptop"}
+	_, generation := c.get("A")
+	c.invalidate(context.Background(), cacheInvalidation{Subject: "sub", SessionIDs: []string{"laptop"}})
+
+	// When:
+	c.add("A", claims, generation)
+
+	// Then: its result is not cached
+	cached, _ := c.get("A")
+	assert.False(t, cached)
+}
+
+func TestVerifyCache_Publish(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+
+	// Mocks:
+	mockPubSub := &mockPubSub{}
+	mockPubSub.On("Publish", mock.Anything, verifyCacheChannel, mock.Anything).
+		Return(goredis.NewIntResult(1, nil))
+
+	// When:
+	NewVerifyCache(mockPubSub, 10, time.Minute).invalidate(ctx, cacheInvalidation{Subject: "sub", SessionIDs: []string{"laptop"}})
+
+	// Then:
+	mockPubSub.AssertNumberOfCalls(t, "Publish", 1)
+	var actual cacheInvalidation
+	require.NoError(t, json.Unmarshal([]byte(mockPubSub.Calls[0].Arguments.String(2)), &actual))
+	assert.Equal(t, cacheInvalidation{Subject: "sub", SessionIDs: []string{"laptop"}}, actual)
+}
+
+func TestVerifyCache_Run(t *testing.T) {
+	t.Parallel()
+
+	// Given: two replicas sharing redis
+	rds, err := redis.New()
+	if err != nil || rds.Ping(context.Background()).Err() != nil {
+		t.Skip("redis is not available")
+	}
+	pubsub, ok := rds.(PubSub)
+	require.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subject, err := newRandomID()
+	require.NoError(t, err)
+	claims := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: subject, ExpiresAt: jwtgo.NewNumericDate(time.Now().Add(time.Hour))}, SessionID: "laptop"}
+
+	replica, other := NewVerifyCache(pubsub, 10, time.Minute), NewVerifyCache(pubsub, 10, time.Minute)
+	go other.Run(ctx)
+
+	// Given: the other replica has verified the token after it subscribed
+	require.Eventually(t, func() bool {
+		n, err := rds.PubSubNumSub(ctx, verifyCacheChannel).Result()
+
+		return err == nil && n[verifyCacheChannel] > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool {
+		_, generation := other.get("A")
+		other.add("A", claims, generation)
+		cached, _ := other.get("A")
+
+		return cached
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// When: the session is logged out on the replica
+	replica.invalidate(ctx, cacheInvalidation{Subject: subject, SessionIDs: []string{"laptop"}})
+
+	// Then: the other replica evicts the token
+	assert.Eventually(t, func() bool {
+		cached, _ := other.get("A")
+
+		return !cached
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestVerifyCacheFromEnv(t *testing.T) {
+	testCases := []struct {
+		size      string
+		ttl       string
+		expNil    bool
+		expTTL    time.Duration
+		expectErr bool
+	}{
+		{size: "", expNil: true},
+		{size: "0", expNil: true},
+		{size: "1000", expTTL: defaultVerifyCacheTTL},
+		{size: "1000", ttl: "10s", expTTL: 10 * time.Second},
+		{size: "-1", expectErr: true},
+		{size: "many", expectErr: true},
+		{size: "1000", ttl: "1h", expectErr: true},
+		{size: "1000", ttl: "0s", expectErr: true},
+		{size: "1000", ttl: "soon", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.size+"_"+tc.ttl, func(t *testing.T) {
+			// Given:
+			t.Setenv("AUTH_VERIFY_CACHE_SIZE", tc.size)
+			t.Setenv("AUTH_VERIFY_CACHE_TTL", tc.ttl)
+
+			// When:
+			actual, err := VerifyCacheFromEnv(NewMemoryStore())
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			if tc.expectErr || tc.expNil {
+				assert.Nil(t, actual)
+				return
+			}
+			require.NotNil(t, actual)
+			assert.Equal(t, tc.expTTL, actual.ttl)
+			assert.Nil(t, actual.pubsub, "the memory store is not shared")
+		})
+	}
+}
+
+// countingStore counts how many times the sessions are looked up
+type countingStore struct {
+	SessionStore
+	gets atomic.Int64
+}
+
+func (s *countingStore) GetSession(ctx context.Context, subject, sessionID string) (StoredSession, error) {
+	s.gets.Add(1)
+
+	return s.SessionStore.GetSession(ctx, subject, sessionID)
+}
+
+type mockPubSub struct {
+	mock.Mock
+}
+
+func (m *mockPubSub) Publish(ctx context.Context, channel string, message interface{}) *goredis.IntCmd {
+	args := m.Called(ctx, channel, message)
+
+	return args.Get(0).(*goredis.IntCmd)
+}
+
+func (m *mockPubSub) Subscribe(ctx context.Context, channels ...string) *goredis.PubSub {
+	args := m.Called(ctx, channels)
+
+	return args.Get(0).(*goredis.PubSub)
+}
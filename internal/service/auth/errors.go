@@ -12,9 +12,25 @@ var (
 	ErrMissingContext = &web.Error{Status: http.StatusInternalServerError, Code: "missing_auth_context", Desc: "Missing auth context"}
 	// ErrMissingToken is the error returned if the access token string is missing
 	ErrMissingToken = &web.Error{Status: http.StatusUnauthorized, Code: "missing_token", Desc: "Missing access token"}
+	// ErrScopeNotGranted is the error returned if a scope requested at login is not granted to the subject
+	ErrScopeNotGranted = &web.Error{Status: http.StatusBadRequest, Code: "invalid_scope", Desc: "Requested scope is not granted"}
+	// ErrRoleNotGranted is the error returned if a role requested at login is not granted to the subject
+	ErrRoleNotGranted = &web.Error{Status: http.StatusBadRequest, Code: "invalid_role", Desc: "Requested role is not granted"}
+	// ErrUnknownAudience is the error returned if the audience requested at login is not a known audience
+	ErrUnknownAudience = &web.Error{Status: http.StatusBadRequest, Code: "invalid_target", Desc: "Requested audience is unknown"}
+	// ErrInsufficientScope is the error returned if the access token is missing a scope required by the route
+	ErrInsufficientScope = &web.Error{Status: http.StatusForbidden, Code: "insufficient_scope", Desc: "Insufficient scope"}
+	// ErrForbidden is the error returned if the claims of the access token do not satisfy the requirements of the route
+	ErrForbidden = &web.Error{Status: http.StatusForbidden, Code: "forbidden", Desc: "Forbidden"}
+	// ErrTokenNotRevocable is the error returned if the token was issued without a token ID, so only logout can invalidate it
+	ErrTokenNotRevocable = &web.Error{Status: http.StatusBadRequest, Code: "unsupported_token_type", Desc: "Token has no id and cannot be revoked"}
+	// ErrSessionNotFound is the error returned if the session to revoke is not one of the sessions of the subject
+	ErrSessionNotFound = &web.Error{Status: http.StatusNotFound, Code: "session_not_found", Desc: "Session not found"}
+	// ErrInvalidGrant is the error returned if the refresh token is unknown, expired, already used or its session is logged out
+	ErrInvalidGrant = &web.Error{Status: http.StatusBadRequest, Code: "invalid_grant", Desc: "Invalid refresh token"}
 	// ErrInactiveToken is the error returned if the token retrieved using the authorization code is inactive
 	ErrInactiveToken = &web.Error{Status: http.StatusBadRequest, Code: "inactive_token", Desc: "inactive token"}
-	// ErrRedis is the generic web error for redis-related errors
+	// ErrRedis is the generic web error for redis-related errors, and the errors of the other session stores
 	ErrRedis = &web.Error{Status: http.StatusInternalServerError, Code: "redis"}
 	// ErrInternal is the generic web error for internal errors
 	ErrInternal = &web.Error{Status: http.StatusInternalServerError, Code: "internal"}
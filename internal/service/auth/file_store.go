@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/logr"
+	"github.com/severedsea/golang-kit/timex"
+)
+
+// defaultCompactionInterval is how often the FileStore is compacted if not configured
+const defaultCompactionInterval = time.Hour
+
+var _ SessionStore = (*FileStore)(nil)
+
+var (
+	errFileStoreClosed = errors.New("file store is closed")
+	// ErrFileStoreLocked is returned when the FileStore is opened while another process or FileStore has it open
+	ErrFileStoreLocked = errors.New("file store is used by another process")
+)
+
+// FileStore is the SessionStore that keeps the sessions in a local file, for single node deployments without redis
+// It is a MemoryStore whose changes are appended to the file and synced before they are applied, so that an
+// acknowledged change survives a crash. The last seen time of the sessions is not synced on every request, it is
+// synced with the next change or on close instead. The file is replayed on open, and compacted to what has not
+// expired on open and in the background, by writing a new file and renaming it over the old one.
+// Only one FileStore may have the file open at a time, which is enforced with an exclusive lock on a lock file next to it.
+type FileStore struct {
+	*MemoryStore
+	path string
+	lock *os.File
+	done chan struct{}
+
+	// The fields below are guarded by the lock of the MemoryStore
+	file *os.File
+	// size is the size of the file after the last change, which a failed write is truncated back to
+	size int64
+	// broken is the error of a failed write that could not be truncated, every change fails until it is compacted
+	broken error
+	closed bool
+}
+
+// OpenFileStore opens the FileStore at path, creating it if it does not exist, and compacts it on every interval
+// The FileStore must be closed to stop the compaction and release the lock of the file.
+// It fails with ErrFileStoreLocked if the file is open already.
+func OpenFileStore(path string, compactionInterval time.Duration) (*FileStore, error) {
+	lock, err := lockFile(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path, lock: lock, done: make(chan struct{})}
+	if err := s.load(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	if err := s.Compact(); err != nil {
+		if s.file != nil {
+			s.file.Close()
+		}
+		lock.Close()
+		return nil, err
+	}
+	s.journal = s.appendRecord
+
+	go s.run(compactionInterval)
+
+	return s, nil
+}
+
+// Compact rewrites the file with only what has not expired
+// The new file is synced before it replaces the old one, so a crash leaves either of them.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errFileStoreClosed
+	}
+
+	s.purge(timex.NowSGT())
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "compact")
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range s.records() {
+		if _, err := writeRecord(w, r); err != nil {
+			f.Close()
+			return errors.Wrap(err, "compact")
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "compact")
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "compact")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "compact")
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return errors.Wrap(err, "compact")
+	}
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		return errors.Wrap(err, "compact")
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "compact")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "compact")
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, s.size, s.broken = file, info.Size(), nil
+
+	return nil
+}
+
+// Close stops the compaction and closes the file, after which every change fails
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+
+	s.journal = func(storeRecord, bool) error {
+		return errFileStoreClosed
+	}
+
+	// The last seen times may not have been synced yet
+	err := s.file.Sync()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	// Closing the lock file releases the lock
+	if cerr := s.lock.Close(); err == nil {
+		err = cerr
+	}
+
+	return errors.Wrap(err, "file store")
+}
+
+// run compacts the file on every interval until the FileStore is closed
+func (s *FileStore) run(interval time.Duration) {
+	logger := logr.GetLogger(context.Background())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.Compact(); errors.Is(err, errFileStoreClosed) {
+			return
+		} else if err != nil {
+			logger.Errorf("[auth] Session store not compacted: %s", err)
+		}
+	}
+}
+
+// load replays the records in the file, if it exists
+// A last record that is cut short or corrupt was not acknowledged, since it was not synced, so it is dropped and
+// compacted away. A corrupt record before the last one fails, since acknowledged changes would be lost.
+func (s *FileStore) load() error {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "load")
+	}
+
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			logr.GetLogger(context.Background()).Warnf("[auth] Session store %s: last record cut short, dropped", s.path)
+			return nil
+		}
+		line := b[:i]
+		b = b[i+1:]
+
+		var r storeRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			if len(b) > 0 {
+				return errors.Wrapf(err, "load %s", s.path)
+			}
+			logr.GetLogger(context.Background()).Warnf("[auth] Session store %s: last record corrupt, dropped: %s", s.path, err)
+			return nil
+		}
+		s.apply(r)
+	}
+
+	return nil
+}
+
+// appendRecord writes the record to the end of the file, and syncs it if it is durable
+// A write that fails is truncated, so that it does not leave a partial record for the next ones to be appended to.
+func (s *FileStore) appendRecord(r storeRecord, durable bool) error {
+	if s.broken != nil {
+		return errors.Wrap(s.broken, "file store")
+	}
+
+	n, err := writeRecord(s.file, r)
+	if err == nil && durable {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		if terr := s.file.Truncate(s.size); terr != nil {
+			s.broken = terr
+		}
+		return errors.Wrap(err, "file store")
+	}
+	s.size += int64(n)
+
+	return nil
+}
+
+// writeRecord writes the record as a line of JSON, and returns the number of bytes written
+func writeRecord(w io.Writer, r storeRecord) (int, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.Write(append(b, '\n'))
+}
+
+// syncDir syncs the directory, so that a rename in it survives a crash
+// lockFile takes an exclusive lock on the lock file at path, creating it if it does not exist
+// The lock is not blocking, it fails with ErrFileStoreLocked if the lock is held already. It is released when the
+// returned file is closed, or when the process exits.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "lock")
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errors.Wrap(ErrFileStoreLocked, path)
+		}
+
+		return nil, errors.Wrap(err, "lock")
+	}
+
+	return f, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	testSessionStore(t, func(t *testing.T) SessionStore {
+		return openTestFileStore(t, filepath.Join(t.TempDir(), "sessions.db"))
+	})
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	now := time.Now().Truncate(time.Second)
+	rt := StoredRefreshToken{Subject: "sub", SessionID: "laptop", ExpiresAt: now.Add(time.Hour)}
+
+	s := openTestFileStore(t, path)
+	require.NoError(t, s.SaveSession(ctx, "sub", "laptop", StoredSession{TokenHash: "HASH", CreatedAt: now, ExpiresAt: now.Add(time.Hour), IdleTimeout: time.Hour}, 0))
+	require.NoError(t, s.SaveSession(ctx, "sub", "phone", StoredSession{TokenHash: "HASH", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0))
+	_, err := s.DeleteSessions(ctx, "sub", "phone")
+	require.NoError(t, err)
+	require.NoError(t, s.RevokeToken(ctx, "TOKEN_ID", "sub", time.Hour))
+	require.NoError(t, s.SaveRefreshToken(ctx, "REFRESH_HASH", rt))
+	_, err = s.UseRefreshToken(ctx, "REFRESH_HASH", rt)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// When:
+	actual := openTestFileStore(t, path)
+
+	// Then: everything is kept as it was
+	list, err := actual.ListSessions(ctx, "sub")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "laptop", list[0].ID)
+	assert.True(t, now.Equal(list[0].LastSeenAt))
+	assert.True(t, now.Add(time.Hour).Equal(list[0].IdleExpiresAt), "idle timeout is not extended by the restart")
+
+	revoked, err := actual.IsRevoked(ctx, "TOKEN_ID")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, err = actual.GetRefreshToken(ctx, "REFRESH_HASH")
+	require.NoError(t, err)
+	ok, err := actual.UseRefreshToken(ctx, "REFRESH_HASH", rt)
+	require.NoError(t, err)
+	assert.False(t, ok, "still used")
+}
+
+func TestFileStore_Crash(t *testing.T) {
+	t.Parallel()
+
+	// Given: the last record was cut short by a crash
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	now := time.Now()
+
+	s := openTestFileStore(t, path)
+	require.NoError(t, s.SaveSession(ctx, "sub", "laptop", StoredSession{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0))
+	require.NoError(t, s.Close())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"kind":"session","subject":"sub","key":"pho`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// When:
+	actual := openTestFileStore(t, path)
+
+	// Then: the acknowledged changes are kept
+	list, err := actual.ListSessions(ctx, "sub")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"laptop"}, sessionIDs(list))
+
+	// Then: the file is usable again
+	require.NoError(t, actual.SaveSession(ctx, "sub", "phone", StoredSession{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0))
+	require.NoError(t, actual.Close())
+	list, err = openTestFileStore(t, path).ListSessions(ctx, "sub")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"laptop", "phone"}, sessionIDs(list))
+}
+
+func TestFileStore_Corrupted(t *testing.T) {
+	t.Parallel()
+
+	session := `{"kind":"session","subject":"sub","key":"laptop","session":{"ExpiresAt":"2999-01-01T00:00:00Z"}}` + "\n"
+
+	testCases := []struct {
+		desc      string
+		given     string
+		expectErr bool
+	}{
+		{desc: "last record corrupt", given: session + "not json\n"},
+		{desc: "record before the last corrupt", given: "not json\n" + session, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			path := filepath.Join(t.TempDir(), "sessions.db")
+			require.NoError(t, os.WriteFile(path, []byte(tc.given), 0o600))
+
+			// When:
+			actual, err := OpenFileStore(path, time.Hour)
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			b, rerr := os.ReadFile(path)
+			require.NoError(t, rerr)
+			if tc.expectErr {
+				assert.Equal(t, tc.given, string(b), "the file is not overwritten")
+				return
+			}
+			t.Cleanup(func() {
+				_ = actual.Close()
+			})
+			assert.NotContains(t, string(b), "not json", "the corrupt record is compacted away")
+			list, err := actual.ListSessions(context.Background(), "sub")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"laptop"}, sessionIDs(list))
+		})
+	}
+}
+
+func TestFileStore_WriteError(t *testing.T) {
+	t.Parallel()
+
+	// Given: the file cannot be written
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	now := time.Now()
+
+	s := openTestFileStore(t, path)
+	readOnly, err := os.Open(path)
+	require.NoError(t, err)
+	s.mu.Lock()
+	s.file.Close()
+	s.file = readOnly
+	s.mu.Unlock()
+
+	// When:
+	err = s.SaveSession(ctx, "sub", "laptop", StoredSession{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0)
+
+	// Then: the change is not applied
+	assert.Error(t, err)
+	_, err = s.GetSession(ctx, "sub", "laptop")
+	assert.Equal(t, ErrSessionNotFound, err)
+
+	// When: it could not be truncated either
+	err = s.RevokeToken(ctx, "TOKEN_ID", "sub", time.Hour)
+
+	// Then: every change fails until it is compacted
+	assert.Error(t, err)
+	require.NoError(t, s.Compact())
+	require.NoError(t, s.RevokeToken(ctx, "TOKEN_ID", "sub", time.Hour))
+	require.NoError(t, s.Close())
+	revoked, err := openTestFileStore(t, path).IsRevoked(ctx, "TOKEN_ID")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestFileStore_Closed(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	now := time.Now()
+	s := openTestFileStore(t, filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, s.Close())
+
+	// When:
+	err := s.Compact()
+
+	// Then: it is not reopened
+	assert.Equal(t, errFileStoreClosed, err)
+	assert.Error(t, s.SaveSession(ctx, "sub", "laptop", StoredSession{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0))
+}
+
+func TestFileStore_Locked(t *testing.T) {
+	t.Parallel()
+
+	// Given: the file is open already
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	s := openTestFileStore(t, path)
+
+	// When:
+	_, err := OpenFileStore(path, time.Hour)
+
+	// Then:
+	assert.ErrorIs(t, err, ErrFileStoreLocked)
+
+	// Then: it can be opened once it is closed
+	require.NoError(t, s.Close())
+	openTestFileStore(t, path)
+}
+
+func TestFileStore_Compact(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	now := time.Now()
+
+	s := openTestFileStore(t, path)
+	require.NoError(t, s.SaveSession(ctx, "sub", "laptop", StoredSession{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0))
+	require.NoError(t, s.SaveSession(ctx, "sub", "phone", StoredSession{CreatedAt: now, ExpiresAt: now.Add(100 * time.Millisecond)}, 0))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.UpdateSession(ctx, "sub", "laptop", func(v *StoredSession) error {
+			v.TokenHash = "HASH"
+
+			return nil
+		}))
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// When:
+	require.NoError(t, s.Compact())
+
+	// Then: only the latest state of what has not expired is kept
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(b), "\n"))
+	assert.Contains(t, string(b), `"key":"laptop"`)
+
+	// Then: changes are still written after the compaction
+	require.NoError(t, s.RevokeToken(ctx, "TOKEN_ID", "sub", time.Hour))
+	require.NoError(t, s.Close())
+	revoked, err := openTestFileStore(t, path).IsRevoked(ctx, "TOKEN_ID")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func openTestFileStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+
+	s, err := OpenFileStore(path, time.Hour)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	return s
+}
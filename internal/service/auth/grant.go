@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/projectpath"
+	"golang.org/x/exp/slices"
+)
+
+// allSubjects is the grant list entry that applies to every subject
+const allSubjects = "*"
+
+// Grant is the scopes and roles that a subject may request at login
+type Grant struct {
+	Scopes []string `json:"scopes"`
+	Roles  []string `json:"roles"`
+}
+
+// GrantStore returns the grant of a subject
+type GrantStore interface {
+	Grant(ctx context.Context, subject string) (Grant, error)
+}
+
+// StaticGrants is a fixed grant list keyed by subject
+// The "*" entry applies to every subject in addition to its own grant.
+type StaticGrants map[string]Grant
+
+// Grant implements GrantStore
+func (g StaticGrants) Grant(_ context.Context, subject string) (Grant, error) {
+	result := g[subject]
+	if all, ok := g[allSubjects]; ok && subject != allSubjects {
+		result.Scopes = append(slices.Clone(result.Scopes), all.Scopes...)
+		result.Roles = append(slices.Clone(result.Roles), all.Roles...)
+	}
+
+	return result, nil
+}
+
+// GrantsFromEnv loads the grant list from the JSON file at AUTH_GRANTS_PATH
+// No scopes or roles are granted if it is not configured.
+//
+//	{"*": {"scopes": ["profile"]}, "123": {"scopes": ["orders:read"], "roles": ["admin"]}}
+func GrantsFromEnv() (StaticGrants, error) {
+	path := os.Getenv("AUTH_GRANTS_PATH")
+	if path == "" {
+		return StaticGrants{}, nil
+	}
+
+	b, err := os.ReadFile(projectpath.Abs(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "AUTH_GRANTS_PATH")
+	}
+
+	var result StaticGrants
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, errors.Wrap(err, "AUTH_GRANTS_PATH")
+	}
+
+	return result, nil
+}
+
+// check returns an error if any of the requested scopes or roles is not granted
+func (g Grant) check(scopes, roles []string) error {
+	for _, it := range scopes {
+		if !slices.Contains(g.Scopes, it) {
+			return ErrScopeNotGranted
+		}
+	}
+	for _, it := range roles {
+		if !slices.Contains(g.Roles, it) {
+			return ErrRoleNotGranted
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticGrants_Grant(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	grants := StaticGrants{
+		"*":   {Scopes: []string{"profile"}},
+		"sub": {Scopes: []string{"orders:read"}, Roles: []string{"admin"}},
+	}
+
+	// When:
+	actual, err := grants.Grant(ctx, "sub")
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, Grant{Scopes: []string{"orders:read", "profile"}, Roles: []string{"admin"}}, actual)
+	assert.Equal(t, []string{"orders:read"}, grants["sub"].Scopes, "grant list is not modified")
+
+	// When: subject without its own grant
+	actual, err = grants.Grant(ctx, "another")
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, Grant{Scopes: []string{"profile"}}, actual)
+}
+
+func TestGrantsFromEnv(t *testing.T) {
+	// Given:
+	path := filepath.Join(t.TempDir(), "grants.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"sub": {"scopes": ["orders:read"], "roles": ["admin"]}}`), 0o600))
+	t.Setenv("AUTH_GRANTS_PATH", path)
+
+	// When:
+	actual, err := GrantsFromEnv()
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, StaticGrants{"sub": {Scopes: []string{"orders:read"}, Roles: []string{"admin"}}}, actual)
+
+	// When: not configured
+	t.Setenv("AUTH_GRANTS_PATH", "")
+	actual, err = GrantsFromEnv()
+
+	// Then:
+	require.NoError(t, err)
+	assert.Empty(t, actual)
+
+	// When: invalid JSON
+	require.NoError(t, os.WriteFile(path, []byte(`{`), 0o600))
+	t.Setenv("AUTH_GRANTS_PATH", path)
+	_, err = GrantsFromEnv()
+
+	// Then:
+	assert.Error(t, err)
+}
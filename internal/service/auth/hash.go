@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/logr"
+)
+
+// minTokenHashKeyLength is the minimum length in bytes of the secret key of the stored token hashes
+const minTokenHashKeyLength = 32
+
+// TokenHashKeyFromEnv returns the secret key of the stored token hashes in AUTH_TOKEN_HASH_KEY
+// Every replica must use the same key. It is required unless AUTH_TOKEN_HASH_KEY_INSECURE=true is set for local development,
+// in which case the tokens are hashed without a secret key.
+func TokenHashKeyFromEnv() ([]byte, error) {
+	v := os.Getenv("AUTH_TOKEN_HASH_KEY")
+	if v == "" {
+		if os.Getenv("AUTH_TOKEN_HASH_KEY_INSECURE") == "true" {
+			return nil, nil
+		}
+
+		return nil, errors.New("AUTH_TOKEN_HASH_KEY is required, set AUTH_TOKEN_HASH_KEY_INSECURE=true to hash tokens without a key in development")
+	}
+	if len(v) < minTokenHashKeyLength {
+		return nil, errors.Errorf("AUTH_TOKEN_HASH_KEY must be at least %d bytes", minTokenHashKeyLength)
+	}
+
+	return []byte(v), nil
+}
+
+// hashToken returns the keyed hash of the token, which is stored instead of the token
+// so that the tokens cannot be replayed by anyone who can read the session store
+func (s Service) hashToken(tokenString string) string {
+	mac := hmac.New(sha256.New, s.tokenHashKey)
+	mac.Write([]byte(tokenString))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// matchToken checks in constant time that the token is the one of the session
+// Sessions stored before tokens were hashed have the token itself.
+func (s Service) matchToken(v StoredSession, tokenString string) bool {
+	if v.TokenHash == "" {
+		return v.AccessToken != "" && subtle.ConstantTimeCompare([]byte(v.AccessToken), []byte(tokenString)) == 1
+	}
+
+	return subtle.ConstantTimeCompare([]byte(v.TokenHash), []byte(s.hashToken(tokenString))) == 1
+}
+
+// migrateSession replaces the token of a session stored before tokens were hashed with its hash
+// It is best-effort, so failures are only logged and the session is migrated the next time it is used.
+func (s Service) migrateSession(ctx context.Context, subject, sessionID string) {
+	err := s.store.UpdateSession(ctx, subject, sessionID, func(v *StoredSession) error {
+		if v.TokenHash != "" {
+			return errors.New("session is migrated already")
+		}
+		v.TokenHash = s.hashToken(v.AccessToken)
+		v.AccessToken = ""
+
+		return nil
+	})
+	if err != nil {
+		logr.GetLogger(ctx).Errorf("migrate session token hash: %s", err)
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashToken(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	s := newTestService(nil, WithTokenHashKey([]byte("secret")))
+
+	// When:
+	actual := s.hashToken("ACCESS_TOKEN")
+
+	// Then:
+	assert.Len(t, actual, 43, "base64url encoded sha256")
+	assert.Equal(t, actual, s.hashToken("ACCESS_TOKEN"))
+	assert.NotEqual(t, actual, s.hashToken("ANOTHER_TOKEN"))
+	assert.NotEqual(t, actual, newTestService(nil, WithTokenHashKey([]byte("another"))).hashToken("ACCESS_TOKEN"), "hash is keyed")
+}
+
+func TestMatchToken(t *testing.T) {
+	t.Parallel()
+
+	s := newTestService(nil, WithTokenHashKey([]byte("secret")))
+
+	testCases := []struct {
+		desc     string
+		given    StoredSession
+		expected bool
+	}{
+		{desc: "hash of the token", given: StoredSession{TokenHash: s.hashToken("ACCESS_TOKEN")}, expected: true},
+		{desc: "hash of another token", given: StoredSession{TokenHash: s.hashToken("ANOTHER_TOKEN")}},
+		{desc: "hash with another key", given: StoredSession{TokenHash: testTokenHash("ACCESS_TOKEN")}},
+		{desc: "token before hashing", given: StoredSession{AccessToken: "ACCESS_TOKEN"}, expected: true},
+		{desc: "another token before hashing", given: StoredSession{AccessToken: "ANOTHER_TOKEN"}},
+		{desc: "hash takes precedence", given: StoredSession{AccessToken: "ACCESS_TOKEN", TokenHash: s.hashToken("ANOTHER_TOKEN")}},
+		{desc: "no token", given: StoredSession{}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			actual := s.matchToken(tc.given, "ACCESS_TOKEN")
+
+			// Then:
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestVerifyToken_Migration(t *testing.T) {
+	t.Parallel()
+
+	// Given: session stored before tokens were hashed
+	ctx := context.Background()
+	subject := "sub"
+	c := Claims{RegisteredClaims: jwt.NewRegisteredClaims(subject, time.Hour), SessionID: "phone"}
+	tokenString, err := testSigner.Sign(ctx, c)
+	require.NoError(t, err)
+
+	store := NewMemoryStore()
+	require.NoError(t, store.SaveSession(ctx, subject, "phone", StoredSession{AccessToken: tokenString, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}, 0))
+
+	// When:
+	err = newTestService(store).VerifyToken(ctx, tokenString, c)
+
+	// Then: the token is replaced by its hash
+	require.NoError(t, err)
+	migrated, err := store.GetSession(ctx, subject, "phone")
+	require.NoError(t, err)
+	assert.Empty(t, migrated.AccessToken)
+	assert.Equal(t, testTokenHash(tokenString), migrated.TokenHash)
+
+	// Then: the token is still valid after the migration
+	assert.NoError(t, newTestService(store).VerifyToken(ctx, tokenString, c))
+}
+
+func TestRefresh_StoredHash(t *testing.T) {
+	t.Parallel()
+
+	// Given: the stored hash of the refresh token has been read from the store
+	ctx := context.Background()
+	rt := StoredRefreshToken{Subject: "sub", SessionID: "phone", ExpiresAt: time.Now().Add(time.Hour)}
+	store := newTestRefreshStore(t, "REFRESH_TOKEN", rt, StoredSession{TokenHash: testTokenHash("ACCESS_TOKEN")})
+	s := newTestService(store)
+
+	// When:
+	_, err := s.Refresh(ctx, testTokenHash("REFRESH_TOKEN"))
+
+	// Then: the hash is not a refresh token
+	assert.Equal(t, ErrInvalidGrant, err)
+
+	// Then: the refresh token is still valid for its holder
+	_, err = s.Refresh(ctx, "REFRESH_TOKEN")
+	assert.NoError(t, err)
+}
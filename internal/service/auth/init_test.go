@@ -1,11 +1,94 @@
 package auth
 
 import (
-	"os"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"time"
 
 	"github.com/severedsea/jwt-server/internal/pkg/jwt"
 )
 
+var (
+	testSigner   *jwt.Signer
+	testVerifier *jwt.Verifier
+)
+
 func init() {
-	jwt.InitKeyFiles(os.Getenv("JWT_PRIVATE_KEY_PATH"), os.Getenv("JWT_PUBLIC_KEY_PATH"))
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	key, err := jwt.NewKey("", privKey, privKey.Public())
+	if err != nil {
+		panic(err)
+	}
+	k, err := jwt.NewKeyRing(key)
+	if err != nil {
+		panic(err)
+	}
+
+	keys := jwt.NewKeyStore(k)
+	if testSigner, err = jwt.NewSigner(keys); err != nil {
+		panic(err)
+	}
+	if testVerifier, err = jwt.NewVerifier(keys); err != nil {
+		panic(err)
+	}
+}
+
+// newTestService creates a Service of the store with the test signer and verifier
+func newTestService(store SessionStore, opts ...Option) Service {
+	return New(store, append([]Option{WithSigner(testSigner), WithVerifier(testVerifier)}, opts...)...)
+}
+
+// errTestStore is the error of every call to failingStore
+var errTestStore = errors.New("store is down")
+
+// failingStore is the SessionStore whose every call fails
+type failingStore struct{}
+
+func (failingStore) SaveSession(context.Context, string, string, StoredSession, int) error {
+	return errTestStore
+}
+
+func (failingStore) GetSession(context.Context, string, string) (StoredSession, error) {
+	return StoredSession{}, errTestStore
+}
+
+func (failingStore) UpdateSession(context.Context, string, string, func(v *StoredSession) error) error {
+	return errTestStore
+}
+
+func (failingStore) TouchSession(context.Context, string, string, StoredSession) error {
+	return errTestStore
+}
+
+func (failingStore) ListSessions(context.Context, string) ([]Session, error) {
+	return nil, errTestStore
+}
+
+func (failingStore) DeleteSessions(context.Context, string, ...string) (int, error) {
+	return 0, errTestStore
+}
+
+func (failingStore) RevokeToken(context.Context, string, string, time.Duration) error {
+	return errTestStore
+}
+
+func (failingStore) IsRevoked(context.Context, string) (bool, error) {
+	return false, errTestStore
+}
+
+func (failingStore) SaveRefreshToken(context.Context, string, StoredRefreshToken) error {
+	return errTestStore
+}
+
+func (failingStore) GetRefreshToken(context.Context, string) (StoredRefreshToken, error) {
+	return StoredRefreshToken{}, errTestStore
+}
+
+func (failingStore) UseRefreshToken(context.Context, string, StoredRefreshToken) (bool, error) {
+	return false, errTestStore
 }
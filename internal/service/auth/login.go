@@ -4,12 +4,37 @@ import (
 	"context"
 
 	"github.com/severedsea/golang-kit/web"
+	"golang.org/x/exp/slices"
 )
 
+// LoginRequest is the subject and the scopes and roles it requests
+type LoginRequest struct {
+	Subject string
+	Scopes  []string
+	Roles   []string
+	// Audience is the service that the token is issued for, which must be a known audience
+	// The token has no `aud` if it is empty, so it is rejected by the services that enforce an audience with AUTH_AUDIENCE.
+	Audience string
+	// UserAgent and IP describe the client in the session
+	UserAgent string
+	IP        string
+}
+
 // Login creates a session and generates an access_token based on the subject provided
-func (s Service) Login(ctx context.Context, subject string) (Token, error) {
+// The requested scopes and roles must be granted to the subject.
+func (s Service) Login(ctx context.Context, req LoginRequest) (Token, error) {
+	g, err := s.grants.Grant(ctx, req.Subject)
+	if err != nil {
+		return Token{}, web.NewError(ErrInternal, err.Error())
+	}
+	if err := g.check(req.Scopes, req.Roles); err != nil {
+		return Token{}, err
+	}
+	if req.Audience != "" && !slices.Contains(s.audiences, req.Audience) {
+		return Token{}, ErrUnknownAudience
+	}
 
-	t, err := s.GenerateToken(ctx, subject)
+	t, err := s.GenerateToken(ctx, req)
 	if err != nil {
 		return Token{}, web.WithStack(err)
 	}
@@ -4,25 +4,20 @@ import (
 	"context"
 	"testing"
 
-	"github.com/go-redis/redis/v8"
+	jwtgo "github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLogin(t *testing.T) {
 	// Given:
 	ctx := context.Background()
 	subject := "sub"
-
-	// Mocks:
-	mockRds := &mockRedis{}
-	mockRds.On("SetArgs", mock.Anything, redisKey(subject),
-		mock.AnythingOfType("redisValue"), mock.AnythingOfType("redis.SetArgs")).
-		Return(redis.NewStatusResult("", nil))
+	store := NewMemoryStore()
 
 	// When:
-	s := New(mockRds)
-	act, err := s.Login(ctx, subject)
+	s := newTestService(store)
+	act, err := s.Login(ctx, LoginRequest{Subject: subject})
 
 	// Then:
 	assert.NoError(t, err)
@@ -32,6 +27,136 @@ func TestLogin(t *testing.T) {
 	assert.NotEmpty(t, act.ExpiresIn)
 	assert.NotEmpty(t, act.ExpiresAt)
 
-	// Assert mocks call
-	mockRds.AssertNumberOfCalls(t, "SetArgs", 1)
+	// Then: the session is stored
+	_, err = store.GetSession(ctx, subject, act.SessionID)
+	assert.NoError(t, err)
+}
+
+func TestLogin_Error(t *testing.T) {
+	t.Parallel()
+
+	// When:
+	_, err := newTestService(failingStore{}).Login(context.Background(), LoginRequest{Subject: "sub"})
+
+	// Then:
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errTestStore.Error())
+}
+
+func TestLogin_Grants(t *testing.T) {
+	t.Parallel()
+
+	grants := StaticGrants{
+		"*":   {Scopes: []string{"profile"}},
+		"sub": {Scopes: []string{"orders:read"}, Roles: []string{"admin"}},
+	}
+
+	testCases := []struct {
+		desc     string
+		given    LoginRequest
+		expScope string
+		expRoles []string
+		expErr   error
+	}{
+		{
+			desc:     "granted",
+			given:    LoginRequest{Subject: "sub", Scopes: []string{"orders:read", "profile", "profile"}, Roles: []string{"admin"}},
+			expScope: "orders:read profile",
+			expRoles: []string{"admin"},
+		},
+		{
+			desc:  "nothing requested",
+			given: LoginRequest{Subject: "another"},
+		},
+		{
+			desc:   "scope not granted",
+			given:  LoginRequest{Subject: "another", Scopes: []string{"orders:read"}},
+			expErr: ErrScopeNotGranted,
+		},
+		{
+			desc:   "role not granted",
+			given:  LoginRequest{Subject: "another", Scopes: []string{"profile"}, Roles: []string{"admin"}},
+			expErr: ErrRoleNotGranted,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+
+			store := NewMemoryStore()
+
+			// When:
+			s := newTestService(store, WithGrants(grants))
+			act, err := s.Login(ctx, tc.given)
+
+			// Then:
+			if tc.expErr != nil {
+				assert.Equal(t, tc.expErr, err)
+				list, err := store.ListSessions(ctx, tc.given.Subject)
+				require.NoError(t, err)
+				assert.Empty(t, list, "no session is stored")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expScope, act.Scope)
+			assert.Equal(t, tc.expRoles, act.Roles)
+
+			claims, err := s.ParseToken(ctx, act.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expScope, claims.Scope)
+			assert.Equal(t, tc.expRoles, claims.Roles)
+		})
+	}
+}
+
+func TestLogin_Audience(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc   string
+		given  string
+		expAud jwtgo.ClaimStrings
+		expErr error
+	}{
+		{desc: "known audience", given: "orders", expAud: jwtgo.ClaimStrings{"orders"}},
+		{desc: "no audience"},
+		{desc: "unknown audience", given: "payments", expErr: ErrUnknownAudience},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			subject := "sub"
+
+			store := NewMemoryStore()
+
+			// When:
+			s := newTestService(store, WithKnownAudiences("orders", "profile"))
+			act, err := s.Login(ctx, LoginRequest{Subject: subject, Audience: tc.given})
+
+			// Then:
+			if tc.expErr != nil {
+				assert.Equal(t, tc.expErr, err)
+				list, err := store.ListSessions(ctx, subject)
+				require.NoError(t, err)
+				assert.Empty(t, list, "no session is stored")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.given, act.Audience)
+
+			claims, err := s.ParseToken(ctx, act.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expAud, claims.Audience)
+		})
+	}
 }
@@ -2,40 +2,23 @@ package auth
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/severedsea/golang-kit/logr"
 	"github.com/severedsea/golang-kit/timex"
 	"github.com/severedsea/golang-kit/web"
 )
 
-// Logout invalidates the access_token for the provided subject
-func (s Service) Logout(ctx context.Context, subject string) error {
+// Logout invalidates the session of the access_token, the other sessions of the subject are still valid
+// Tokens issued before sessions were introduced are stored as the single token of the subject, which is deleted instead.
+func (s Service) Logout(ctx context.Context, c Claims) error {
 	logger := logr.GetLogger(ctx)
 	startTime := timex.NowSGT()
 
-	// retrieve value from redis
-	var v redisValue
-	key := redisKey(subject)
-	if err := s.redis.Get(ctx, key).Scan(&v); err != nil {
-		if errors.Is(err, redis.Nil) {
-			return nil
-		}
-
+	if _, err := s.store.DeleteSessions(ctx, c.Subject, c.SessionID); err != nil {
 		return web.NewError(ErrRedis, err.Error())
 	}
-
-	// delete key in redis
-	d, err := s.redis.Del(ctx, key).Result()
-	if err != nil {
-		return web.NewError(ErrRedis, err.Error())
-	}
-	if d < 1 {
-		return web.NewError(ErrRedis, fmt.Sprintf("key %s was not deleted", key))
-	}
+	s.cache.invalidate(ctx, cacheInvalidation{Subject: c.Subject, SessionIDs: []string{c.SessionID}})
 
 	logger.
 		WithField("duration", time.Since(startTime).Milliseconds()).
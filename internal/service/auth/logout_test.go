@@ -2,147 +2,57 @@ package auth
 
 import (
 	"context"
-	"errors"
 	"testing"
 
-	"github.com/go-redis/redis/v8"
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLogout(t *testing.T) {
-	subject := "sub"
-	key := redisKey(subject)
-
-	testCases := []struct {
-		desc    string
-		mocks   func(r *mockRedis)
-		asserts func(t *testing.T, r *mockRedis)
-	}{
-		{
-			desc: "success",
-			mocks: func(r *mockRedis) {
-				r.On("Get", mock.Anything, key).
-					Return(redis.NewStringResult(`{"AccessToken":"ACCESS_TOKEN"}`, nil)).
-					On("Del", mock.Anything, []string{key}).
-					Return(redis.NewIntResult(1, nil))
-			},
-			asserts: func(t *testing.T, r *mockRedis) {
-				r.AssertNumberOfCalls(t, "Get", 1)
-				r.AssertNumberOfCalls(t, "Del", 1)
-			},
-		},
-		{
-			desc: "redis.Nil error",
-			mocks: func(r *mockRedis) {
-				r.On("Get", mock.Anything, key).
-					Return(redis.NewStringResult("", redis.Nil))
-			},
-			asserts: func(t *testing.T, r *mockRedis) {
-				r.AssertNumberOfCalls(t, "Get", 1)
-				r.AssertNotCalled(t, "Del")
-			},
-		},
-	}
-	for _, tc := range testCases {
-		tc := tc
-		t.Run(tc.desc, func(t *testing.T) {
-			t.Parallel()
-
-			// Given:
-			ctx := context.Background()
-
-			// Mocks:
-			mockRds := &mockRedis{}
-			tc.mocks(mockRds)
-
-			// When:
-			s := New(mockRds)
-			err := s.Logout(ctx, subject)
-
-			// Then:
-			assert.NoError(t, err)
+	t.Parallel()
 
-			// Assert mocks call
-			tc.asserts(t, mockRds)
-		})
-	}
+	// Given: subject is logged in on two devices
+	ctx := context.Background()
+	subject := "sub"
+	s := newTestService(NewMemoryStore())
+
+	laptop, err := s.Login(ctx, LoginRequest{Subject: subject})
+	require.NoError(t, err)
+	phone, err := s.Login(ctx, LoginRequest{Subject: subject})
+	require.NoError(t, err)
+	c, err := s.ParseToken(ctx, phone.AccessToken)
+	require.NoError(t, err)
+
+	// When:
+	err = s.Logout(ctx, c)
+
+	// Then: only the session is logged out
+	require.NoError(t, err)
+	assert.Equal(t, jwt.ErrInvalidToken, s.VerifyToken(ctx, phone.AccessToken, c))
+	list, err := s.Sessions(ctx, c)
+	require.NoError(t, err)
+	assert.Equal(t, []string{laptop.SessionID}, sessionIDs(list))
+
+	// When: the session is logged out already
+	err = s.Logout(ctx, c)
+
+	// Then:
+	assert.NoError(t, err)
 }
 
 func TestLogout_Error(t *testing.T) {
 	t.Parallel()
 
-	subject := "sub"
-	key := redisKey(subject)
-	givenErr := errors.New("something happened")
-
-	testCases := []struct {
-		desc    string
-		mocks   func(r *mockRedis)
-		asserts func(t *testing.T, r *mockRedis)
-		exp     error
-	}{
-		{
-			desc: "redis Get error",
-			mocks: func(r *mockRedis) {
-				r.On("Get", mock.Anything, key).
-					Return(redis.NewStringResult(``, givenErr))
-			},
-			asserts: func(t *testing.T, r *mockRedis) {
-				r.AssertNumberOfCalls(t, "Get", 1)
-				r.AssertNotCalled(t, "Del")
-			},
-			exp: ErrRedis,
-		},
-		{
-			desc: "redis Del error",
-			mocks: func(r *mockRedis) {
-				r.On("Get", mock.Anything, key).
-					Return(redis.NewStringResult(`{"AccessToken":"ACCESS_TOKEN"}`, nil)).
-					On("Del", mock.Anything, []string{key}).
-					Return(redis.NewIntResult(0, givenErr))
-			},
-			asserts: func(t *testing.T, r *mockRedis) {
-				r.AssertNumberOfCalls(t, "Get", 1)
-				r.AssertNumberOfCalls(t, "Del", 1)
-			},
-			exp: ErrRedis,
-		},
-		{
-			desc: "redis Del error: key not deleted error",
-			mocks: func(r *mockRedis) {
-				r.On("Get", mock.Anything, key).
-					Return(redis.NewStringResult(`{"AccessToken":"ACCESS_TOKEN"}`, nil)).
-					On("Del", mock.Anything, []string{key}).
-					Return(redis.NewIntResult(0, nil))
-			},
-			asserts: func(t *testing.T, r *mockRedis) {
-				r.AssertNumberOfCalls(t, "Get", 1)
-				r.AssertNumberOfCalls(t, "Del", 1)
-			},
-			exp: ErrRedis,
-		},
-	}
-	for _, tc := range testCases {
-		tc := tc
-		t.Run(tc.desc, func(t *testing.T) {
-			// Given:
-			ctx := context.Background()
-
-			// Mocks:
-			mockRds := &mockRedis{}
-			tc.mocks(mockRds)
-
-			// 	When:
-			s := New(mockRds)
-			err := s.Logout(ctx, subject)
+	// Given:
+	ctx := context.Background()
+	c := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: "sub"}, SessionID: "phone"}
 
-			// Then:
-			assert.Error(t, err)
-			assert.ErrorIs(t, err, tc.exp)
+	// When:
+	err := newTestService(failingStore{}).Logout(ctx, c)
 
-			// Assert mocks call
-			tc.asserts(t, mockRds)
-		})
-	}
+	// Then:
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrRedis)
 }
@@ -0,0 +1,393 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/severedsea/golang-kit/timex"
+)
+
+var _ SessionStore = (*MemoryStore)(nil)
+
+// MemoryStore is the SessionStore that keeps the sessions in memory
+// It is meant for local development and tests, since the sessions are lost on restart and not shared between replicas.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]*memorySession
+	revoked  map[string]time.Time
+	refresh  map[string]StoredRefreshToken
+	used     map[string]time.Time
+
+	// journal is given every change before it is applied, so that the FileStore can persist it
+	// The changes that are not durable may be lost on a crash, which is fine for e.g. the last seen time of a session.
+	journal func(r storeRecord, durable bool) error
+}
+
+// memorySession is a stored session with the state that the redis store keeps in other keys
+type memorySession struct {
+	StoredSession
+	lastSeenAt    time.Time
+	idleExpiresAt time.Time
+}
+
+// storeRecord is a change of the MemoryStore
+// Records keep absolute times, so that replaying them later gives the same state.
+type storeRecord struct {
+	Kind          string              `json:"kind"`
+	Subject       string              `json:"subject,omitempty"`
+	Key           string              `json:"key"`
+	Deleted       bool                `json:"deleted,omitempty"`
+	Session       *StoredSession      `json:"session,omitempty"`
+	LastSeenAt    time.Time           `json:"last_seen_at"`
+	IdleExpiresAt time.Time           `json:"idle_expires_at"`
+	RefreshToken  *StoredRefreshToken `json:"refresh_token,omitempty"`
+	ExpiresAt     time.Time           `json:"expires_at"`
+}
+
+const (
+	recordSession = "session"
+	recordRevoked = "revoked"
+	recordRefresh = "refresh"
+	recordUsed    = "used"
+)
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: map[string]map[string]*memorySession{},
+		revoked:  map[string]time.Time{},
+		refresh:  map[string]StoredRefreshToken{},
+		used:     map[string]time.Time{},
+	}
+}
+
+// SaveSession stores the session in memory
+func (m *MemoryStore) SaveSession(_ context.Context, subject, sessionID string, v StoredSession, maxSessions int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := timex.NowSGT()
+	m.purge(now)
+
+	sessions := m.sessions[subject]
+	all := make(map[string]StoredSession, len(sessions))
+	for id, it := range sessions {
+		if it.isIdle(now) {
+			if err := m.deleteSession(subject, id); err != nil {
+				return err
+			}
+			continue
+		}
+		all[id] = it.StoredSession
+	}
+	for _, id := range staleSessions(all, maxSessions, now) {
+		if err := m.deleteSession(subject, id); err != nil {
+			return err
+		}
+	}
+
+	it := memorySession{StoredSession: v, lastSeenAt: v.CreatedAt}
+	if v.IdleTimeout > 0 {
+		it.idleExpiresAt = v.CreatedAt.Add(v.IdleTimeout)
+	}
+
+	return m.setSession(subject, sessionID, it)
+}
+
+// GetSession returns the session from memory
+func (m *MemoryStore) GetSession(_ context.Context, subject, sessionID string) (StoredSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.sessions[subject][sessionID]
+	if !ok {
+		return StoredSession{}, ErrSessionNotFound
+	}
+
+	return it.StoredSession, nil
+}
+
+// UpdateSession replaces the session in memory, which is atomic since the store is locked meanwhile
+func (m *MemoryStore) UpdateSession(_ context.Context, subject, sessionID string, update func(v *StoredSession) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.sessions[subject][sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	updated := *it
+	if err := update(&updated.StoredSession); err != nil {
+		return err
+	}
+
+	return m.setSession(subject, sessionID, updated)
+}
+
+// TouchSession extends the idle timeout of the session, if any, and updates its last seen time
+func (m *MemoryStore) TouchSession(_ context.Context, subject, sessionID string, _ StoredSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.sessions[subject][sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	now := timex.NowSGT()
+	if it.isIdle(now) {
+		return errSessionIdle
+	}
+
+	touched := *it
+	if it.IdleTimeout > 0 {
+		touched.idleExpiresAt = now.Add(it.IdleTimeout)
+	}
+	if now.Sub(it.lastSeenAt) >= lastSeenResolution {
+		touched.lastSeenAt = now
+	}
+	if touched == *it {
+		return nil
+	}
+
+	// Not durable, since losing it only ends the session earlier on a crash
+	return m.write(sessionRecord(subject, sessionID, touched), false)
+}
+
+// ListSessions returns the sessions in memory that have not ended
+func (m *MemoryStore) ListSessions(_ context.Context, subject string) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := timex.NowSGT()
+	result := make([]Session, 0, len(m.sessions[subject]))
+	for id, it := range m.sessions[subject] {
+		if !now.Before(it.ExpiresAt) || it.isIdle(now) {
+			continue
+		}
+
+		v := newSession(id, it.StoredSession)
+		v.LastSeenAt = it.lastSeenAt
+		v.IdleExpiresAt = it.idleExpiresAt
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+// DeleteSessions deletes the sessions from memory
+func (m *MemoryStore) DeleteSessions(_ context.Context, subject string, sessionIDs ...string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int
+	for _, id := range sessionIDs {
+		if _, ok := m.sessions[subject][id]; ok {
+			if err := m.deleteSession(subject, id); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// RevokeToken adds the token ID to the revocation list in memory
+func (m *MemoryStore) RevokeToken(_ context.Context, tokenID, _ string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := timex.NowSGT()
+	m.purge(now)
+
+	return m.write(storeRecord{Kind: recordRevoked, Key: tokenID, ExpiresAt: now.Add(ttl)}, true)
+}
+
+// IsRevoked checks if the token ID is in the revocation list in memory
+func (m *MemoryStore) IsRevoked(_ context.Context, tokenID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiresAt, ok := m.revoked[tokenID]
+
+	return ok && timex.NowSGT().Before(expiresAt), nil
+}
+
+// SaveRefreshToken stores the refresh token in memory
+func (m *MemoryStore) SaveRefreshToken(_ context.Context, tokenHash string, rt StoredRefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.purge(timex.NowSGT())
+
+	return m.write(storeRecord{Kind: recordRefresh, Key: tokenHash, RefreshToken: &rt}, true)
+}
+
+// GetRefreshToken returns the refresh token from memory
+func (m *MemoryStore) GetRefreshToken(_ context.Context, tokenHash string) (StoredRefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rt, ok := m.refresh[tokenHash]
+	if !ok || !timex.NowSGT().Before(rt.ExpiresAt) {
+		return StoredRefreshToken{}, ErrInvalidGrant
+	}
+
+	return rt, nil
+}
+
+// UseRefreshToken marks the refresh token as used in memory
+func (m *MemoryStore) UseRefreshToken(_ context.Context, tokenHash string, rt StoredRefreshToken) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := timex.NowSGT()
+	if expiresAt, ok := m.used[tokenHash]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	if err := m.write(storeRecord{Kind: recordUsed, Key: tokenHash, ExpiresAt: rt.ExpiresAt}, true); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setSession stores the session of the subject
+func (m *MemoryStore) setSession(subject, sessionID string, it memorySession) error {
+	return m.write(sessionRecord(subject, sessionID, it), true)
+}
+
+// sessionRecord is the record that stores the session of the subject
+func sessionRecord(subject, sessionID string, it memorySession) storeRecord {
+	v := it.StoredSession
+
+	return storeRecord{
+		Kind:          recordSession,
+		Subject:       subject,
+		Key:           sessionID,
+		Session:       &v,
+		LastSeenAt:    it.lastSeenAt,
+		IdleExpiresAt: it.idleExpiresAt,
+	}
+}
+
+// deleteSession deletes the session of the subject
+func (m *MemoryStore) deleteSession(subject, sessionID string) error {
+	return m.write(storeRecord{Kind: recordSession, Subject: subject, Key: sessionID, Deleted: true}, true)
+}
+
+// write gives the change to the journal, if any, and applies it only if it was journaled
+func (m *MemoryStore) write(r storeRecord, durable bool) error {
+	if m.journal != nil {
+		if err := m.journal(r, durable); err != nil {
+			return err
+		}
+	}
+	m.apply(r)
+
+	return nil
+}
+
+// apply changes the store with the record
+func (m *MemoryStore) apply(r storeRecord) {
+	switch r.Kind {
+	case recordSession:
+		if r.Deleted {
+			delete(m.sessions[r.Subject], r.Key)
+			if len(m.sessions[r.Subject]) == 0 {
+				delete(m.sessions, r.Subject)
+			}
+			return
+		}
+		if r.Session == nil {
+			return
+		}
+		if m.sessions[r.Subject] == nil {
+			m.sessions[r.Subject] = map[string]*memorySession{}
+		}
+		m.sessions[r.Subject][r.Key] = &memorySession{
+			StoredSession: *r.Session,
+			lastSeenAt:    r.LastSeenAt,
+			idleExpiresAt: r.IdleExpiresAt,
+		}
+
+	case recordRevoked:
+		m.revoked[r.Key] = r.ExpiresAt
+
+	case recordRefresh:
+		if r.RefreshToken != nil {
+			m.refresh[r.Key] = *r.RefreshToken
+		}
+
+	case recordUsed:
+		m.used[r.Key] = r.ExpiresAt
+	}
+}
+
+// records returns the records that rebuild the current state of the store
+func (m *MemoryStore) records() []storeRecord {
+	var result []storeRecord
+	for subject, sessions := range m.sessions {
+		for id, it := range sessions {
+			v := it.StoredSession
+			result = append(result, storeRecord{
+				Kind:          recordSession,
+				Subject:       subject,
+				Key:           id,
+				Session:       &v,
+				LastSeenAt:    it.lastSeenAt,
+				IdleExpiresAt: it.idleExpiresAt,
+			})
+		}
+	}
+	for id, expiresAt := range m.revoked {
+		result = append(result, storeRecord{Kind: recordRevoked, Key: id, ExpiresAt: expiresAt})
+	}
+	for hash, rt := range m.refresh {
+		rt := rt
+		result = append(result, storeRecord{Kind: recordRefresh, Key: hash, RefreshToken: &rt})
+	}
+	for hash, expiresAt := range m.used {
+		result = append(result, storeRecord{Kind: recordUsed, Key: hash, ExpiresAt: expiresAt})
+	}
+
+	return result
+}
+
+// purge removes what has expired, like redis does with the TTL of the keys
+// It is linear in the size of the store, which is fine for the intended use.
+func (m *MemoryStore) purge(now time.Time) {
+	for subject, sessions := range m.sessions {
+		for id, it := range sessions {
+			if !now.Before(it.ExpiresAt) || it.isIdle(now) {
+				delete(sessions, id)
+			}
+		}
+		if len(sessions) == 0 {
+			delete(m.sessions, subject)
+		}
+	}
+	for id, expiresAt := range m.revoked {
+		if !now.Before(expiresAt) {
+			delete(m.revoked, id)
+		}
+	}
+	for hash, rt := range m.refresh {
+		if !now.Before(rt.ExpiresAt) {
+			delete(m.refresh, hash)
+		}
+	}
+	for hash, expiresAt := range m.used {
+		if !now.Before(expiresAt) {
+			delete(m.used, hash)
+		}
+	}
+}
+
+// isIdle checks if the session has been idle for too long
+func (it *memorySession) isIdle(now time.Time) bool {
+	return it.IdleTimeout > 0 && !now.Before(it.idleExpiresAt)
+}
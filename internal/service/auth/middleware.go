@@ -44,7 +44,7 @@ func validateToken(ctx context.Context, r *http.Request, p TokenParserVerifier)
 		return Claims{}, err
 	}
 
-	if err := p.VerifyToken(ctx, token, c.Subject); err != nil {
+	if err := p.VerifyToken(ctx, token, c); err != nil {
 		return Claims{}, err
 	}
 
@@ -84,7 +84,7 @@ func TestMiddleware(t *testing.T) {
 				Return(Claims{
 					RegisteredClaims: jwtgo.RegisteredClaims{Subject: "SUBJECT"},
 				}, nil)
-			stub.On("VerifyToken", mock.Anything, tokenString, "SUBJECT").
+			stub.On("VerifyToken", mock.Anything, tokenString, mock.MatchedBy(func(c Claims) bool { return c.Subject == "SUBJECT" })).
 				Return(nil)
 
 			// When:
@@ -221,8 +221,8 @@ func (m *mockTokenParserVerifier) ParseToken(ctx context.Context, tokenString st
 	return args.Get(0).(Claims), args.Error(1)
 }
 
-func (m *mockTokenParserVerifier) VerifyToken(ctx context.Context, tokenString, subject string) error {
-	args := m.Called(ctx, tokenString, subject)
+func (m *mockTokenParserVerifier) VerifyToken(ctx context.Context, tokenString string, c Claims) error {
+	args := m.Called(ctx, tokenString, c)
 
 	return args.Error(0)
 }
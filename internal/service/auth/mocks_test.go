@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/mock"
@@ -13,20 +14,89 @@ type mockRedis struct {
 	redis.Cmdable
 }
 
-func (m *mockRedis) SetArgs(ctx context.Context, key string, value interface{}, a redis.SetArgs) *redis.StatusCmd {
-	args := m.Called(ctx, key, value, a)
+func (m *mockRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	args := m.Called(ctx, key)
+
+	return args.Get(0).(*redis.StringCmd)
+}
+
+func (m *mockRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	args := m.Called(ctx, keys)
+
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	args := m.Called(ctx, key, value, expiration)
 
 	return args.Get(0).(*redis.StatusCmd)
 }
 
-func (m *mockRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+func (m *mockRedis) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	args := m.Called(ctx, keys)
+
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd {
 	args := m.Called(ctx, key)
 
+	return args.Get(0).(*redis.StringStringMapCmd)
+}
+
+func (m *mockRedis) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	args := m.Called(ctx, key, field)
+
 	return args.Get(0).(*redis.StringCmd)
 }
 
-func (m *mockRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
-	args := m.Called(ctx, keys)
+func (m *mockRedis) HKeys(ctx context.Context, key string) *redis.StringSliceCmd {
+	args := m.Called(ctx, key)
+
+	return args.Get(0).(*redis.StringSliceCmd)
+}
+
+func (m *mockRedis) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	args := m.Called(ctx, key, values)
 
 	return args.Get(0).(*redis.IntCmd)
 }
+
+func (m *mockRedis) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	args := m.Called(ctx, key, fields)
+
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	args := m.Called(ctx, key, expiration)
+
+	return args.Get(0).(*redis.BoolCmd)
+}
+
+// onSaveSession mocks the redis calls that save a new session of the subject without other sessions
+// HGetAll is called once per saved session.
+func (m *mockRedis) onSaveSession(subject string) *mockRedis {
+	m.On("HGetAll", mock.Anything, sessionsKey(subject)).
+		Return(redis.NewStringStringMapResult(map[string]string{}, nil))
+	for _, key := range []string{sessionsKey(subject), lastSeenKey(subject)} {
+		m.On("HSet", mock.Anything, key, mock.Anything).
+			Return(redis.NewIntResult(1, nil))
+		m.On("EvalSha", mock.Anything, mock.Anything, []string{key}, mock.Anything).
+			Return(redis.NewCmdResult(int64(1), nil))
+	}
+
+	return m
+}
+
+func (m *mockRedis) PTTL(ctx context.Context, key string) *redis.DurationCmd {
+	args := m.Called(ctx, key)
+
+	return args.Get(0).(*redis.DurationCmd)
+}
+
+func (m *mockRedis) EvalSha(ctx context.Context, sha1 string, keys []string, a ...interface{}) *redis.Cmd {
+	args := m.Called(ctx, sha1, keys, a)
+
+	return args.Get(0).(*redis.Cmd)
+}
@@ -2,20 +2,136 @@ package auth
 
 import (
 	"context"
+	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
 )
 
-// New creates a new Service struct
-func New(rds redis.Cmdable) Service {
-	return Service{
-		redis: rds,
+// New creates a new Service struct that keeps the sessions in the store, see StoreFromEnv
+// The default jwt signer and verifier are used unless provided in the options.
+// No scopes or roles are granted unless a grant store is provided.
+func New(store SessionStore, opts ...Option) Service {
+	s := Service{
+		store:    store,
+		signer:   jwt.DefaultSigner(),
+		verifier: jwt.DefaultVerifier(),
+		grants:   StaticGrants{},
+
+		refreshTokenLifetime: defaultRefreshTokenLifetime,
+		sessionLifetime:      defaultSessionLifetime,
+	}
+	for _, opt := range opts {
+		opt(&s)
 	}
+
+	return s
+}
+
+// Option configures the Service
+type Option func(s *Service)
+
+// WithSigner sets the jwt signer used to sign the access tokens
+func WithSigner(signer *jwt.Signer) Option {
+	return func(s *Service) {
+		s.signer = signer
+	}
+}
+
+// WithVerifier sets the jwt verifier used to parse the access tokens
+func WithVerifier(verifier *jwt.Verifier) Option {
+	return func(s *Service) {
+		s.verifier = verifier
+	}
+}
+
+// WithGrants sets the grant store that the scopes and roles requested at login are checked against
+func WithGrants(grants GrantStore) Option {
+	return func(s *Service) {
+		s.grants = grants
+	}
+}
+
+// WithKnownAudiences sets the audiences that tokens may be issued for at login
+func WithKnownAudiences(audiences ...string) Option {
+	return func(s *Service) {
+		s.audiences = audiences
+	}
+}
+
+// WithAudience sets the audience that the parsed tokens must be issued for
+func WithAudience(audience string) Option {
+	return func(s *Service) {
+		s.audience = audience
+	}
+}
+
+// WithMaxSessions sets the maximum number of concurrent sessions per subject, the oldest sessions are logged out first
+// The number of sessions is not limited if it is 0.
+func WithMaxSessions(n int) Option {
+	return func(s *Service) {
+		s.maxSessions = n
+	}
+}
+
+// WithRefreshTokenLifetime sets how long a refresh token can be used, within the lifetime of its session
+func WithRefreshTokenLifetime(d time.Duration) Option {
+	return func(s *Service) {
+		s.refreshTokenLifetime = d
+	}
+}
+
+// WithSessionLifetime sets the absolute lifetime of new sessions, which is never extended
+func WithSessionLifetime(d time.Duration) Option {
+	return func(s *Service) {
+		s.sessionLifetime = d
+	}
+}
+
+// WithIdleTimeout sets how long new sessions may be unused before they are logged out
+// Sessions are not logged out when idle if it is 0.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Service) {
+		s.idleTimeout = d
+	}
+}
+
+// WithTokenHashKey sets the secret key of the stored token hashes
+func WithTokenHashKey(key []byte) Option {
+	return func(s *Service) {
+		s.tokenHashKey = key
+	}
+}
+
+// WithVerifyCache sets the cache of the verified tokens, which is shared by the services of every route group
+func WithVerifyCache(cache *VerifyCache) Option {
+	return func(s *Service) {
+		s.cache = cache
+	}
+}
+
+// ForAudience returns a copy of the Service that only accepts tokens issued for the audience
+// It is meant for route groups that belong to a different audience than the rest of the deployment.
+func (s Service) ForAudience(audience string) Service {
+	s.audience = audience
+
+	return s
 }
 
 // Service holds the methods for this package
 type Service struct {
-	redis redis.Cmdable
+	store       SessionStore
+	signer      *jwt.Signer
+	verifier    *jwt.Verifier
+	grants      GrantStore
+	audiences   []string
+	audience    string
+	maxSessions int
+
+	refreshTokenLifetime time.Duration
+	sessionLifetime      time.Duration
+	idleTimeout          time.Duration
+	tokenHashKey         []byte
+	cache                *VerifyCache
 }
 
 // TokenParser is the interface for the token parser
@@ -24,7 +140,7 @@ type TokenParser interface {
 }
 
 type TokenVerifier interface {
-	VerifyToken(ctx context.Context, tokenString, subject string) error
+	VerifyToken(ctx context.Context, tokenString string, c Claims) error
 }
 
 type TokenParserVerifier interface {
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	store := NewMemoryStore()
+
+	// When:
+	actual := New(store)
+
+	// Then:
+	assert.Same(t, store, actual.store)
+	assert.Same(t, jwt.DefaultSigner(), actual.signer)
+	assert.Same(t, jwt.DefaultVerifier(), actual.verifier)
+
+	// When:
+	actual = New(store, WithSigner(testSigner), WithVerifier(testVerifier))
+
+	// Then:
+	assert.Same(t, testSigner, actual.signer)
+	assert.Same(t, testVerifier, actual.verifier)
+}
@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/logr"
+	"github.com/severedsea/golang-kit/timex"
+)
+
+var (
+	errSessionChanged = errors.New("session has changed")
+	errSessionIdle    = errors.New("session has been idle for too long")
+)
+
+// updateSessionScript replaces the session only if it has not changed since it was read,
+// so that updating a session can never bring it back after a logout
+var updateSessionScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// extendExpiryScript sets the expiry of the key only if it would expire earlier, or does not expire at all,
+// so that saving a session never shortens the lifetime of the other sessions of the subject
+var extendExpiryScript = redis.NewScript(`
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl == -1 or ttl < tonumber(ARGV[1]) then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+var _ SessionStore = RedisStore{}
+
+// RedisStore is the SessionStore that keeps the sessions in redis, shared by every replica
+type RedisStore struct {
+	redis redis.Cmdable
+}
+
+// NewRedisStore creates a new RedisStore
+func NewRedisStore(rds redis.Cmdable) RedisStore {
+	return RedisStore{redis: rds}
+}
+
+/*
+redisValue is the value for storing token related data in redis
+
+	It will implement encoding.BinaryMarshaler and encoding.BinaryUnMarshaler so that go-redis can unmarshal it automatically
+
+https://github.com/go-redis/redis/issues/739#issuecomment-418185046
+*/
+type redisValue struct {
+	AccessToken string `redis:"AccessToken"`
+}
+
+func (v redisValue) MarshalBinary() ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (v *redisValue) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, &v)
+}
+
+// SaveSession stores the session in the redis hash of the subject
+// The limit is best-effort if the subject logs in concurrently.
+func (r RedisStore) SaveSession(ctx context.Context, subject, sessionID string, v StoredSession, maxSessions int) error {
+	key := sessionsKey(subject)
+
+	all, err := r.redis.HGetAll(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	sessions, stale := decodeSessions(all)
+	idle, err := r.idleSessions(ctx, subject, sessions)
+	if err != nil {
+		return err
+	}
+	for _, it := range idle {
+		delete(sessions, it)
+	}
+
+	if stale = append(append(stale, idle...), staleSessions(sessions, maxSessions, timex.NowSGT())...); len(stale) > 0 {
+		if err := r.redis.HDel(ctx, key, stale...).Err(); err != nil {
+			return err
+		}
+		if err := r.redis.HDel(ctx, lastSeenKey(subject), stale...).Err(); err != nil {
+			return err
+		}
+	}
+
+	if err := r.redis.HSet(ctx, key, sessionID, v).Err(); err != nil {
+		return err
+	}
+	if err := r.redis.HSet(ctx, lastSeenKey(subject), sessionID, v.CreatedAt.Unix()).Err(); err != nil {
+		return err
+	}
+
+	// The hashes expire with the session that expires last, which is not always the newest one
+	// if the session lifetime has been changed. Each key is extended on its own, as they may be in another cluster slot.
+	ttl := time.Until(v.ExpiresAt).Milliseconds()
+	for _, it := range []string{key, lastSeenKey(subject)} {
+		if err := extendExpiryScript.Run(ctx, r.redis, []string{it}, ttl).Err(); err != nil {
+			return err
+		}
+	}
+
+	if v.IdleTimeout <= 0 {
+		return nil
+	}
+
+	return r.redis.Set(ctx, idleKey(subject, sessionID), v.CreatedAt.Unix(), v.IdleTimeout).Err()
+}
+
+// GetSession returns the session of the subject from its redis hash
+// Sessions stored before session IDs were introduced are the single token of the subject.
+func (r RedisStore) GetSession(ctx context.Context, subject, sessionID string) (StoredSession, error) {
+	if sessionID == "" {
+		var v redisValue
+		if err := r.redis.Get(ctx, redisKey(subject)).Scan(&v); err != nil {
+			if errors.Is(err, redis.Nil) {
+				return StoredSession{}, ErrSessionNotFound
+			}
+
+			return StoredSession{}, err
+		}
+
+		return StoredSession{AccessToken: v.AccessToken}, nil
+	}
+
+	var v StoredSession
+	if err := r.redis.HGet(ctx, sessionsKey(subject), sessionID).Scan(&v); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return StoredSession{}, ErrSessionNotFound
+		}
+
+		return StoredSession{}, err
+	}
+
+	return v, nil
+}
+
+// UpdateSession replaces the session with a script that checks that it has not changed since it was read
+func (r RedisStore) UpdateSession(ctx context.Context, subject, sessionID string, update func(v *StoredSession) error) error {
+	key := sessionsKey(subject)
+
+	raw, err := r.redis.HGet(ctx, key, sessionID).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrSessionNotFound
+		}
+
+		return err
+	}
+
+	var v StoredSession
+	if err := v.UnmarshalBinary([]byte(raw)); err != nil {
+		return err
+	}
+	if err := update(&v); err != nil {
+		return err
+	}
+
+	b, err := v.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	n, err := updateSessionScript.Run(ctx, r.redis, []string{key}, sessionID, raw, string(b)).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errSessionChanged
+	}
+
+	return nil
+}
+
+// TouchSession extends the idle timeout key of the session, if any, and updates its last seen time
+// The last seen time is updated at most once per lastSeenResolution and is best-effort, so failures are only logged.
+func (r RedisStore) TouchSession(ctx context.Context, subject, sessionID string, v StoredSession) error {
+	if sessionID == "" {
+		return nil
+	}
+
+	if v.IdleTimeout > 0 {
+		ok, err := r.redis.Expire(ctx, idleKey(subject, sessionID), v.IdleTimeout).Result()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errSessionIdle
+		}
+	}
+
+	now := timex.NowSGT()
+
+	unix, err := r.redis.HGet(ctx, lastSeenKey(subject), sessionID).Int64()
+	if err == nil && now.Sub(time.Unix(unix, 0)) < lastSeenResolution {
+		return nil
+	}
+
+	if err := r.redis.HSet(ctx, lastSeenKey(subject), sessionID, now.Unix()).Err(); err != nil {
+		logr.GetLogger(ctx).Errorf("update session last seen: %s", err)
+	}
+
+	return nil
+}
+
+// ListSessions returns the sessions in the redis hash of the subject that have not ended
+func (r RedisStore) ListSessions(ctx context.Context, subject string) ([]Session, error) {
+	all, err := r.redis.HGetAll(ctx, sessionsKey(subject)).Result()
+	if err != nil {
+		return nil, err
+	}
+	lastSeen, err := r.redis.HGetAll(ctx, lastSeenKey(subject)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := timex.NowSGT()
+	sessions, _ := decodeSessions(all)
+	result := make([]Session, 0, len(sessions))
+	for id, v := range sessions {
+		if !now.Before(v.ExpiresAt) {
+			continue
+		}
+
+		it := newSession(id, v)
+		if unix, err := strconv.ParseInt(lastSeen[id], 10, 64); err == nil {
+			it.LastSeenAt = time.Unix(unix, 0)
+		}
+		if v.IdleTimeout > 0 {
+			ttl, err := r.redis.PTTL(ctx, idleKey(subject, id)).Result()
+			if err != nil {
+				return nil, err
+			}
+			// The session has been idle for too long if the key has expired
+			if ttl <= 0 {
+				continue
+			}
+			it.IdleExpiresAt = now.Add(ttl)
+		}
+		result = append(result, it)
+	}
+
+	return result, nil
+}
+
+// DeleteSessions deletes the sessions from the redis hashes of the subject
+// The idle timeout keys are not deleted since the sessions are already gone, they just expire.
+func (r RedisStore) DeleteSessions(ctx context.Context, subject string, sessionIDs ...string) (int, error) {
+	if len(sessionIDs) == 1 && sessionIDs[0] == "" {
+		return r.deleteLegacySession(ctx, subject)
+	}
+
+	n, err := r.redis.HDel(ctx, sessionsKey(subject), sessionIDs...).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := r.redis.HDel(ctx, lastSeenKey(subject), sessionIDs...).Err(); err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// deleteLegacySession deletes the single token of the subject stored before sessions were introduced
+func (r RedisStore) deleteLegacySession(ctx context.Context, subject string) (int, error) {
+	// retrieve value from redis
+	var v redisValue
+	key := redisKey(subject)
+	if err := r.redis.Get(ctx, key).Scan(&v); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	// delete key in redis
+	d, err := r.redis.Del(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if d < 1 {
+		return 0, fmt.Errorf("key %s was not deleted", key)
+	}
+
+	return int(d), nil
+}
+
+// RevokeToken sets the revocation key of the token ID to expire with the token
+func (r RedisStore) RevokeToken(ctx context.Context, tokenID, subject string, ttl time.Duration) error {
+	return r.redis.Set(ctx, revokedKey(tokenID), subject, ttl).Err()
+}
+
+// IsRevoked checks if the revocation key of the token ID exists
+func (r RedisStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	n, err := r.redis.Exists(ctx, revokedKey(tokenID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// SaveRefreshToken sets the key of the refresh token to expire with it
+func (r RedisStore) SaveRefreshToken(ctx context.Context, tokenHash string, rt StoredRefreshToken) error {
+	return r.redis.Set(ctx, refreshKey(tokenHash), rt, time.Until(rt.ExpiresAt)).Err()
+}
+
+// GetRefreshToken returns the refresh token from its key
+func (r RedisStore) GetRefreshToken(ctx context.Context, tokenHash string) (StoredRefreshToken, error) {
+	var rt StoredRefreshToken
+	if err := r.redis.Get(ctx, refreshKey(tokenHash)).Scan(&rt); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return StoredRefreshToken{}, ErrInvalidGrant
+		}
+
+		return StoredRefreshToken{}, err
+	}
+
+	return rt, nil
+}
+
+// UseRefreshToken sets the used key of the refresh token with SETNX
+func (r RedisStore) UseRefreshToken(ctx context.Context, tokenHash string, rt StoredRefreshToken) (bool, error) {
+	return r.redis.SetNX(ctx, refreshUsedKey(tokenHash), rt.SessionID, time.Until(rt.ExpiresAt)).Result()
+}
+
+// idleSessions returns the IDs of the sessions whose idle timeout key has expired
+func (r RedisStore) idleSessions(ctx context.Context, subject string, sessions map[string]StoredSession) ([]string, error) {
+	var result []string
+	for id, v := range sessions {
+		if v.IdleTimeout <= 0 {
+			continue
+		}
+
+		n, err := r.redis.Exists(ctx, idleKey(subject, id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			result = append(result, id)
+		}
+	}
+
+	return result, nil
+}
+
+// decodeSessions decodes the redis hash of the sessions of a subject, and returns the IDs of the invalid entries
+func decodeSessions(all map[string]string) (map[string]StoredSession, []string) {
+	result := make(map[string]StoredSession, len(all))
+	var invalid []string
+	for id, raw := range all {
+		var v StoredSession
+		if err := v.UnmarshalBinary([]byte(raw)); err != nil {
+			invalid = append(invalid, id)
+			continue
+		}
+		result[id] = v
+	}
+
+	return result, invalid
+}
+
+func redisKey(subject string) string {
+	return "auth_" + subject
+}
+
+func sessionsKey(subject string) string {
+	return "sessions_" + subject
+}
+
+// lastSeenKey is the redis hash of the last seen unix time of the sessions of the subject
+// It is kept apart from the sessions so that updating it can never bring back a session that was just logged out.
+func lastSeenKey(subject string) string {
+	return "last_seen_" + subject
+}
+
+// idleKey expires when the session has been idle for too long, its TTL is extended whenever the session is used
+func idleKey(subject, sessionID string) string {
+	return "idle_" + subject + "_" + sessionID
+}
+
+func revokedKey(tokenID string) string {
+	return "revoked_" + tokenID
+}
+
+// refreshKey is the redis key of the refresh token with the hash
+func refreshKey(tokenHash string) string {
+	return "refresh_" + tokenHash
+}
+
+// refreshUsedKey marks the refresh token with the hash as used, it is kept until the refresh token expires to detect reuse
+func refreshUsedKey(tokenHash string) string {
+	return "refresh_used_" + tokenHash
+}
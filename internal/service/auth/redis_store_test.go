@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStore_SaveSession(t *testing.T) {
+	t.Parallel()
+
+	// Given: subject has an idle session and a session on another device
+	ctx := context.Background()
+	subject := "sub"
+	now := time.Now()
+	idle, err := StoredSession{TokenHash: "HASH", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour), IdleTimeout: time.Minute}.MarshalBinary()
+	require.NoError(t, err)
+
+	// Mocks:
+	mockRds := &mockRedis{}
+	mockRds.On("HGetAll", mock.Anything, sessionsKey(subject)).
+		Return(redis.NewStringStringMapResult(map[string]string{
+			"laptop": string(idle),
+			"phone":  newTestSession(t, now.Add(-time.Minute), now.Add(time.Hour)),
+		}, nil))
+	mockRds.On("Exists", mock.Anything, []string{idleKey(subject, "laptop")}).
+		Return(redis.NewIntResult(0, nil))
+	mockRds.On("HDel", mock.Anything, sessionsKey(subject), mock.Anything).
+		Return(redis.NewIntResult(2, nil))
+	mockRds.On("HDel", mock.Anything, lastSeenKey(subject), mock.Anything).
+		Return(redis.NewIntResult(2, nil))
+	mockRds.On("Set", mock.Anything, idleKey(subject, "tablet"), now.Unix(), 10*time.Minute).
+		Return(redis.NewStatusResult("OK", nil))
+	mockRds.onSaveSession(subject)
+
+	// When: only one session is allowed
+	err = NewRedisStore(mockRds).SaveSession(ctx, subject, "tablet", StoredSession{
+		TokenHash:   "HASH",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+		IdleTimeout: 10 * time.Minute,
+	}, 1)
+
+	// Then: the idle and the other sessions are removed
+	require.NoError(t, err)
+	for _, key := range []string{sessionsKey(subject), lastSeenKey(subject)} {
+		mockRds.AssertCalled(t, "HDel", mock.Anything, key, mock.MatchedBy(func(ids []string) bool {
+			return assert.ElementsMatch(t, []string{"laptop", "phone"}, ids)
+		}))
+	}
+
+	// Then: the session is saved with its idle timeout key
+	mockRds.AssertCalled(t, "HSet", mock.Anything, lastSeenKey(subject), []interface{}{"tablet", now.Unix()})
+	mockRds.AssertCalled(t, "Set", mock.Anything, idleKey(subject, "tablet"), now.Unix(), 10*time.Minute)
+
+	// Then: the hashes are only extended up to the session expiry
+	for _, key := range []string{sessionsKey(subject), lastSeenKey(subject)} {
+		mockRds.AssertCalled(t, "EvalSha", mock.Anything, extendExpiryScript.Hash(), []string{key}, mock.MatchedBy(func(args []interface{}) bool {
+			return len(args) == 1 && assert.InDelta(t, time.Hour.Milliseconds(), args[0], float64(time.Second.Milliseconds()))
+		}))
+	}
+}
+
+func TestRedisStore_SaveSession_Error(t *testing.T) {
+	t.Parallel()
+
+	// Mocks:
+	mockRds := &mockRedis{}
+	mockRds.On("HGetAll", mock.Anything, sessionsKey("sub")).
+		Return(redis.NewStringStringMapResult(nil, redis.ErrClosed))
+
+	// When:
+	err := NewRedisStore(mockRds).SaveSession(context.Background(), "sub", "phone", StoredSession{}, 0)
+
+	// Then:
+	assert.Equal(t, redis.ErrClosed, err)
+	mockRds.AssertNotCalled(t, "HSet")
+}
+
+func TestRedisStore_UpdateSession(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc     string
+		given    int64
+		expected error
+	}{
+		{desc: "updated", given: 1},
+		{desc: "changed in the meantime", given: 0, expected: errSessionChanged},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			current := newTestSession(t, time.Now(), time.Now().Add(time.Hour))
+
+			// Mocks:
+			mockRds := &mockRedis{}
+			mockRds.On("HGet", mock.Anything, sessionsKey("sub"), "phone").
+				Return(redis.NewStringResult(current, nil))
+			mockRds.On("EvalSha", mock.Anything, mock.Anything, []string{sessionsKey("sub")}, mock.Anything).
+				Return(redis.NewCmdResult(tc.given, nil))
+
+			// When:
+			err := NewRedisStore(mockRds).UpdateSession(ctx, "sub", "phone", func(v *StoredSession) error {
+				v.TokenHash = "NEW_HASH"
+
+				return nil
+			})
+
+			// Then: the session is replaced only if it has not changed since it was read
+			assert.Equal(t, tc.expected, err)
+			args := mockRds.Calls[1].Arguments.Get(3).([]interface{})
+			assert.Equal(t, "phone", args[0])
+			assert.Equal(t, current, args[1])
+			var updated StoredSession
+			require.NoError(t, updated.UnmarshalBinary([]byte(args[2].(string))))
+			assert.Equal(t, "NEW_HASH", updated.TokenHash)
+		})
+	}
+}
+
+func TestRedisStore_TouchSession_IdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc     string
+		given    *redis.BoolCmd
+		expected error
+	}{
+		{desc: "idle timeout extended", given: redis.NewBoolResult(true, nil)},
+		{desc: "idle for too long", given: redis.NewBoolResult(false, nil), expected: errSessionIdle},
+		{desc: "redis error", given: redis.NewBoolResult(false, redis.ErrClosed), expected: redis.ErrClosed},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+
+			// Mocks:
+			mockRds := &mockRedis{}
+			mockRds.On("Expire", mock.Anything, idleKey("sub", "phone"), 10*time.Minute).
+				Return(tc.given)
+			mockRds.On("HGet", mock.Anything, lastSeenKey("sub"), "phone").
+				Return(redis.NewStringResult(strconv.FormatInt(time.Now().Unix(), 10), nil))
+
+			// When:
+			err := NewRedisStore(mockRds).TouchSession(ctx, "sub", "phone", StoredSession{IdleTimeout: 10 * time.Minute})
+
+			// Then:
+			assert.Equal(t, tc.expected, err)
+			mockRds.AssertNumberOfCalls(t, "Expire", 1)
+		})
+	}
+}
+
+func TestRedisStore_TouchSession(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	testCases := []struct {
+		desc     string
+		lastSeen *redis.StringCmd
+		expCalls int
+	}{
+		{desc: "recently seen", lastSeen: redis.NewStringResult(strconv.FormatInt(now.Unix(), 10), nil)},
+		{desc: "seen a while ago", lastSeen: redis.NewStringResult(strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), nil), expCalls: 1},
+		{desc: "never seen", lastSeen: redis.NewStringResult("", redis.Nil), expCalls: 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+
+			// Mocks:
+			mockRds := &mockRedis{}
+			mockRds.On("HGet", mock.Anything, lastSeenKey("sub"), "phone").
+				Return(tc.lastSeen)
+			mockRds.On("HSet", mock.Anything, lastSeenKey("sub"), mock.Anything).
+				Return(redis.NewIntResult(1, nil))
+
+			// When:
+			err := NewRedisStore(mockRds).TouchSession(ctx, "sub", "phone", StoredSession{})
+
+			// Then:
+			assert.NoError(t, err)
+			mockRds.AssertNumberOfCalls(t, "HSet", tc.expCalls)
+		})
+	}
+}
+
+func TestRedisStore_ListSessions_IdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	now := time.Now()
+	active, err := StoredSession{TokenHash: "PHONE", CreatedAt: now, ExpiresAt: now.Add(time.Hour), IdleTimeout: 10 * time.Minute}.MarshalBinary()
+	require.NoError(t, err)
+	idle, err := StoredSession{TokenHash: "LAPTOP", CreatedAt: now, ExpiresAt: now.Add(time.Hour), IdleTimeout: 10 * time.Minute}.MarshalBinary()
+	require.NoError(t, err)
+
+	// Mocks:
+	mockRds := &mockRedis{}
+	mockRds.On("HGetAll", mock.Anything, sessionsKey("sub")).
+		Return(redis.NewStringStringMapResult(map[string]string{"phone": string(active), "laptop": string(idle)}, nil))
+	mockRds.On("HGetAll", mock.Anything, lastSeenKey("sub")).
+		Return(redis.NewStringStringMapResult(map[string]string{
+			"phone": strconv.FormatInt(now.Add(-time.Minute).Unix(), 10),
+		}, nil))
+	mockRds.On("PTTL", mock.Anything, idleKey("sub", "phone")).
+		Return(redis.NewDurationResult(5*time.Minute, nil))
+	mockRds.On("PTTL", mock.Anything, idleKey("sub", "laptop")).
+		Return(redis.NewDurationResult(-2, nil))
+
+	// When:
+	actual, err := NewRedisStore(mockRds).ListSessions(ctx, "sub")
+
+	// Then: the session whose idle timeout key has expired is left out
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, "phone", actual[0].ID)
+	assert.WithinDuration(t, now.Add(5*time.Minute), actual[0].IdleExpiresAt, time.Second)
+	assert.Equal(t, now.Add(-time.Minute).Unix(), actual[0].LastSeenAt.Unix())
+}
+
+func TestRedisStore_LegacySession(t *testing.T) {
+	t.Parallel()
+
+	// Given: token stored before sessions were introduced
+	subject := "sub"
+	c := Claims{RegisteredClaims: jwt.NewRegisteredClaims(subject, time.Hour)}
+	tokenString, err := testSigner.Sign(context.Background(), c)
+	require.NoError(t, err)
+	b, err := json.Marshal(redisValue{AccessToken: tokenString})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc     string
+		given    *redis.StringCmd
+		expected error
+	}{
+		{desc: "token found", given: redis.NewStringResult(string(b), nil)},
+		{desc: "token not found", given: redis.NewStringResult("", redis.Nil), expected: jwt.ErrInvalidToken},
+		{desc: "another token", given: redis.NewStringResult(`{"AccessToken":"ANOTHER_TOKEN"}`, nil), expected: jwt.ErrInvalidToken},
+		{desc: "redis error", given: redis.NewStringResult("", redis.ErrClosed), expected: jwt.ErrInvalidToken},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+
+			// Mocks:
+			mockRds := &mockRedis{}
+			mockRds.On("Get", mock.Anything, redisKey(subject)).
+				Return(tc.given)
+
+			// When:
+			err := newTestService(NewRedisStore(mockRds)).VerifyToken(ctx, tokenString, c)
+
+			// Then: the single token of the subject is checked
+			assert.Equal(t, tc.expected, err)
+			mockRds.AssertNumberOfCalls(t, "Get", 1)
+		})
+	}
+}
+
+func TestRedisStore_DeleteLegacySession(t *testing.T) {
+	t.Parallel()
+
+	subject := "sub"
+	key := redisKey(subject)
+	givenErr := errors.New("something happened")
+
+	testCases := []struct {
+		desc      string
+		mocks     func(r *mockRedis)
+		expected  int
+		expectErr bool
+		expDel    int
+	}{
+		{
+			desc: "deleted",
+			mocks: func(r *mockRedis) {
+				r.On("Get", mock.Anything, key).
+					Return(redis.NewStringResult(`{"AccessToken":"ACCESS_TOKEN"}`, nil)).
+					On("Del", mock.Anything, []string{key}).
+					Return(redis.NewIntResult(1, nil))
+			},
+			expected: 1,
+			expDel:   1,
+		},
+		{
+			desc: "not found",
+			mocks: func(r *mockRedis) {
+				r.On("Get", mock.Anything, key).
+					Return(redis.NewStringResult("", redis.Nil))
+			},
+		},
+		{
+			desc: "redis Get error",
+			mocks: func(r *mockRedis) {
+				r.On("Get", mock.Anything, key).
+					Return(redis.NewStringResult(``, givenErr))
+			},
+			expectErr: true,
+		},
+		{
+			desc: "redis Del error",
+			mocks: func(r *mockRedis) {
+				r.On("Get", mock.Anything, key).
+					Return(redis.NewStringResult(`{"AccessToken":"ACCESS_TOKEN"}`, nil)).
+					On("Del", mock.Anything, []string{key}).
+					Return(redis.NewIntResult(0, givenErr))
+			},
+			expectErr: true,
+			expDel:    1,
+		},
+		{
+			desc: "key not deleted",
+			mocks: func(r *mockRedis) {
+				r.On("Get", mock.Anything, key).
+					Return(redis.NewStringResult(`{"AccessToken":"ACCESS_TOKEN"}`, nil)).
+					On("Del", mock.Anything, []string{key}).
+					Return(redis.NewIntResult(0, nil))
+			},
+			expectErr: true,
+			expDel:    1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+
+			// Mocks:
+			mockRds := &mockRedis{}
+			tc.mocks(mockRds)
+
+			// When:
+			actual, err := NewRedisStore(mockRds).DeleteSessions(ctx, subject, "")
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			assert.Equal(t, tc.expected, actual)
+			mockRds.AssertNumberOfCalls(t, "Get", 1)
+			mockRds.AssertNumberOfCalls(t, "Del", tc.expDel)
+			mockRds.AssertNotCalled(t, "HDel")
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/logr"
+	"github.com/severedsea/golang-kit/timex"
+	"github.com/severedsea/golang-kit/web"
+)
+
+const (
+	// defaultRefreshTokenLifetime is how long a refresh token can be used, within the lifetime of its session
+	defaultRefreshTokenLifetime = 24 * time.Hour
+	// defaultSessionLifetime is the absolute lifetime of a session
+	defaultSessionLifetime = 24 * time.Hour
+)
+
+/*
+StoredRefreshToken is the session that a refresh token renews, and the claims of the access tokens it issues
+
+	It will implement encoding.BinaryMarshaler and encoding.BinaryUnMarshaler so that go-redis can unmarshal it automatically
+*/
+type StoredRefreshToken struct {
+	Subject   string    `json:"Subject"`
+	SessionID string    `json:"SessionID"`
+	Scopes    []string  `json:"Scopes,omitempty"`
+	Roles     []string  `json:"Roles,omitempty"`
+	Audience  string    `json:"Audience,omitempty"`
+	ExpiresAt time.Time `json:"ExpiresAt"`
+}
+
+func (v StoredRefreshToken) MarshalBinary() ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (v *StoredRefreshToken) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, &v)
+}
+
+// RefreshTokenLifetimeFromEnv returns how long a refresh token can be used in AUTH_REFRESH_TOKEN_LIFETIME
+// It is 24h if not configured. Refresh tokens never outlive their session.
+func RefreshTokenLifetimeFromEnv() (time.Duration, error) {
+	v := os.Getenv("AUTH_REFRESH_TOKEN_LIFETIME")
+	if v == "" {
+		return defaultRefreshTokenLifetime, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.Wrap(err, "AUTH_REFRESH_TOKEN_LIFETIME")
+	}
+	if d < tokenExpiryDuration {
+		return 0, errors.Errorf("AUTH_REFRESH_TOKEN_LIFETIME must be at least the access token lifetime of %s", tokenExpiryDuration)
+	}
+
+	return d, nil
+}
+
+// Refresh issues a new access token and refresh token for the session of the refresh token
+// Each refresh token can only be used once. If a used refresh token comes back, it may have been stolen,
+// so the session is logged out, which revokes the whole family of tokens that it issued.
+func (s Service) Refresh(ctx context.Context, tokenString string) (Token, error) {
+	rt, err := s.getRefreshToken(ctx, tokenString)
+	if err != nil {
+		return Token{}, err
+	}
+
+	// Mark the refresh token as used, this is atomic so that it cannot be used twice concurrently
+	ok, err := s.store.UseRefreshToken(ctx, s.hashToken(tokenString), rt)
+	if err != nil {
+		return Token{}, web.NewError(ErrRedis, err.Error())
+	}
+	if !ok {
+		logr.GetLogger(ctx).
+			WithField("sid", rt.SessionID).
+			Errorf("refresh token reused, revoking the session")
+
+		if err := s.RevokeSession(ctx, rt.Subject, rt.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return Token{}, err
+		}
+
+		return Token{}, ErrInvalidGrant
+	}
+
+	// The session may have been logged out, or idle for too long
+	v, err := s.getSession(ctx, rt.Subject, rt.SessionID)
+	if err != nil {
+		return Token{}, ErrInvalidGrant
+	}
+	if err := s.store.TouchSession(ctx, rt.Subject, rt.SessionID, v); err != nil {
+		return Token{}, ErrInvalidGrant
+	}
+
+	// The grants of the subject may have changed since login
+	g, err := s.grants.Grant(ctx, rt.Subject)
+	if err != nil {
+		return Token{}, web.NewError(ErrInternal, err.Error())
+	}
+	if err := g.check(rt.Scopes, rt.Roles); err != nil {
+		return Token{}, ErrInvalidGrant
+	}
+
+	t, _, err := s.signAccessToken(ctx, LoginRequest{
+		Subject:  rt.Subject,
+		Scopes:   rt.Scopes,
+		Roles:    rt.Roles,
+		Audience: rt.Audience,
+	}, rt.SessionID)
+	if err != nil {
+		return Token{}, err
+	}
+
+	// The previous access token of the session is no longer valid
+	if err := s.store.UpdateSession(ctx, rt.Subject, rt.SessionID, func(v *StoredSession) error {
+		v.AccessToken = ""
+		v.TokenHash = s.hashToken(t.AccessToken)
+
+		return nil
+	}); err != nil {
+		// The session may have been logged out in the meantime
+		return Token{}, ErrInvalidGrant
+	}
+	s.cache.invalidate(ctx, cacheInvalidation{Subject: rt.Subject, SessionIDs: []string{rt.SessionID}})
+
+	rt.ExpiresAt = s.refreshTokenExpiry(timex.NowSGT(), v.ExpiresAt)
+	t.RefreshToken, err = s.saveRefreshToken(ctx, rt)
+	if err != nil {
+		return Token{}, web.NewError(ErrRedis, err.Error())
+	}
+
+	return t, nil
+}
+
+// refreshTokenExpiry returns when a refresh token issued now expires, which is at the latest when its session expires
+func (s Service) refreshTokenExpiry(now, sessionExpiresAt time.Time) time.Time {
+	if expiresAt := now.Add(s.refreshTokenLifetime); expiresAt.Before(sessionExpiresAt) {
+		return expiresAt
+	}
+
+	return sessionExpiresAt
+}
+
+// saveRefreshToken stores a new refresh token until it expires, and returns it
+func (s Service) saveRefreshToken(ctx context.Context, rt StoredRefreshToken) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	tokenString := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := s.store.SaveRefreshToken(ctx, s.hashToken(tokenString), rt); err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (s Service) getRefreshToken(ctx context.Context, tokenString string) (StoredRefreshToken, error) {
+	if tokenString == "" {
+		return StoredRefreshToken{}, ErrInvalidGrant
+	}
+
+	// Only the hash is ever looked up, so that a stored hash cannot be presented as a refresh token
+	rt, err := s.store.GetRefreshToken(ctx, s.hashToken(tokenString))
+	if err != nil {
+		if errors.Is(err, ErrInvalidGrant) {
+			return StoredRefreshToken{}, ErrInvalidGrant
+		}
+
+		return StoredRefreshToken{}, web.NewError(ErrRedis, err.Error())
+	}
+
+	return rt, nil
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresh(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	rt := StoredRefreshToken{
+		Subject:   "sub",
+		SessionID: "phone",
+		Scopes:    []string{"profile"},
+		Audience:  "orders",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	store := newTestRefreshStore(t, "REFRESH_TOKEN", rt, StoredSession{TokenHash: testTokenHash("PREVIOUS_ACCESS_TOKEN")})
+
+	// When:
+	s := newTestService(store, WithGrants(StaticGrants{"sub": {Scopes: []string{"profile"}}}))
+	actual, err := s.Refresh(ctx, "REFRESH_TOKEN")
+
+	// Then: new access token of the same session
+	require.NoError(t, err)
+	assert.NotEmpty(t, actual.RefreshToken)
+	assert.NotEqual(t, "REFRESH_TOKEN", actual.RefreshToken)
+	assert.Equal(t, "phone", actual.SessionID)
+	assert.Equal(t, "profile", actual.Scope)
+
+	c, err := s.ParseToken(ctx, actual.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "sub", c.Subject)
+	assert.Equal(t, "phone", c.SessionID)
+	assert.Equal(t, []string{"orders"}, []string(c.Audience))
+
+	// Then: the session has the new access token
+	updated, err := store.GetSession(ctx, "sub", "phone")
+	require.NoError(t, err)
+	assert.Equal(t, testTokenHash(actual.AccessToken), updated.TokenHash)
+	assert.Empty(t, updated.AccessToken)
+
+	// Then: the new refresh token belongs to the same session and expires with the previous one
+	next, err := store.GetRefreshToken(ctx, testTokenHash(actual.RefreshToken))
+	require.NoError(t, err)
+	assert.Equal(t, rt.SessionID, next.SessionID)
+	assert.True(t, rt.ExpiresAt.Equal(next.ExpiresAt))
+}
+
+func TestRefresh_Reused(t *testing.T) {
+	t.Parallel()
+
+	// Given: refresh token was used already
+	ctx := context.Background()
+	rt := StoredRefreshToken{Subject: "sub", SessionID: "phone", ExpiresAt: time.Now().Add(time.Hour)}
+	store := newTestRefreshStore(t, "REFRESH_TOKEN", rt, StoredSession{TokenHash: testTokenHash("ACCESS_TOKEN")})
+	_, err := store.UseRefreshToken(ctx, testTokenHash("REFRESH_TOKEN"), rt)
+	require.NoError(t, err)
+
+	// When:
+	_, err = newTestService(store).Refresh(ctx, "REFRESH_TOKEN")
+
+	// Then: the session and so the whole token family is revoked
+	assert.Equal(t, ErrInvalidGrant, err)
+	_, err = store.GetSession(ctx, "sub", "phone")
+	assert.Equal(t, ErrSessionNotFound, err)
+	assert.Len(t, store.refresh, 1, "no refresh token is issued")
+}
+
+func TestRefresh_Error(t *testing.T) {
+	t.Parallel()
+
+	rt := StoredRefreshToken{Subject: "sub", SessionID: "phone", Scopes: []string{"profile"}, ExpiresAt: time.Now().Add(time.Hour)}
+
+	testCases := []struct {
+		desc    string
+		given   string
+		session *StoredSession
+	}{
+		{desc: "empty"},
+		{desc: "unknown or expired", given: "UNKNOWN", session: &StoredSession{TokenHash: testTokenHash("ACCESS_TOKEN")}},
+		{desc: "session logged out", given: "REFRESH_TOKEN"},
+		{
+			desc:    "session idle for too long",
+			given:   "REFRESH_TOKEN",
+			session: &StoredSession{TokenHash: testTokenHash("ACCESS_TOKEN"), CreatedAt: time.Now().Add(-time.Hour), IdleTimeout: time.Minute},
+		},
+		{desc: "scope no longer granted", given: "REFRESH_TOKEN", session: &StoredSession{TokenHash: testTokenHash("ACCESS_TOKEN")}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			var store *MemoryStore
+			if tc.session != nil {
+				store = newTestRefreshStore(t, "REFRESH_TOKEN", rt, *tc.session)
+			} else {
+				store = NewMemoryStore()
+				require.NoError(t, store.SaveRefreshToken(ctx, testTokenHash("REFRESH_TOKEN"), rt))
+			}
+
+			// When:
+			_, err := newTestService(store).Refresh(ctx, tc.given)
+
+			// Then: no token is issued
+			assert.Equal(t, ErrInvalidGrant, err)
+			assert.Len(t, store.refresh, 1)
+			if v, err := store.GetSession(ctx, "sub", "phone"); err == nil {
+				assert.Equal(t, testTokenHash("ACCESS_TOKEN"), v.TokenHash)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	now := time.Now()
+	s := newTestService(nil, WithRefreshTokenLifetime(time.Hour))
+
+	// Then: refresh tokens never outlive their session
+	assert.Equal(t, now.Add(time.Hour), s.refreshTokenExpiry(now, now.Add(2*time.Hour)))
+	assert.Equal(t, now.Add(30*time.Minute), s.refreshTokenExpiry(now, now.Add(30*time.Minute)))
+}
+
+func TestRefreshTokenLifetimeFromEnv(t *testing.T) {
+	testCases := []struct {
+		given     string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{given: "", expected: defaultRefreshTokenLifetime},
+		{given: "168h", expected: 168 * time.Hour},
+		{given: "1m", expectErr: true},
+		{given: "week", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.given, func(t *testing.T) {
+			// Given:
+			t.Setenv("AUTH_REFRESH_TOKEN_LIFETIME", tc.given)
+
+			// When:
+			actual, err := RefreshTokenLifetimeFromEnv()
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			if !tc.expectErr {
+				assert.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
+
+// newTestRefreshStore returns a MemoryStore with the refresh token and its session, which expires with it
+func newTestRefreshStore(t *testing.T, tokenString string, rt StoredRefreshToken, v StoredSession) *MemoryStore {
+	t.Helper()
+
+	ctx := context.Background()
+	store := NewMemoryStore()
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	v.ExpiresAt = rt.ExpiresAt
+	require.NoError(t, store.SaveSession(ctx, rt.Subject, rt.SessionID, v, 0))
+	require.NoError(t, store.SaveRefreshToken(ctx, testTokenHash(tokenString), rt))
+
+	return store
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/severedsea/golang-kit/logr"
+	"github.com/severedsea/golang-kit/web"
+)
+
+// Revoke revokes the access_token without touching the other tokens of the subject
+// Invalid tokens are ignored as there is nothing to revoke, as described in RFC 7009.
+func (s Service) Revoke(ctx context.Context, tokenString string) error {
+	c, err := s.ParseToken(ctx, tokenString)
+	if err != nil {
+		return nil
+	}
+
+	return s.RevokeToken(ctx, c)
+}
+
+// RevokeToken adds the token ID to the revocation list until the token expires
+func (s Service) RevokeToken(ctx context.Context, c Claims) error {
+	if c.ID == "" {
+		return ErrTokenNotRevocable
+	}
+	if c.ExpiresAt == nil {
+		return web.NewError(ErrInternal, "token has no expiry")
+	}
+
+	// The revocation is not needed once the token expires
+	ttl := time.Until(c.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+
+	if err := s.store.RevokeToken(ctx, c.ID, c.Subject, ttl); err != nil {
+		return web.NewError(ErrRedis, err.Error())
+	}
+	s.cache.invalidate(ctx, cacheInvalidation{TokenID: c.ID})
+
+	logr.GetLogger(ctx).
+		WithField("jti", c.ID).
+		Infof("token revoked")
+
+	return nil
+}
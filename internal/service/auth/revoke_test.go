@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevoke(t *testing.T) {
+	t.Parallel()
+
+	// Given: subject is logged in
+	ctx := context.Background()
+	store := NewMemoryStore()
+	s := newTestService(store)
+	token, err := s.Login(ctx, LoginRequest{Subject: "sub"})
+	require.NoError(t, err)
+	c, err := s.ParseToken(ctx, token.AccessToken)
+	require.NoError(t, err)
+
+	// When:
+	err = s.Revoke(ctx, token.AccessToken)
+
+	// Then:
+	require.NoError(t, err)
+	revoked, err := store.IsRevoked(ctx, c.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Then: the revocation expires with the token
+	assert.WithinDuration(t, c.ExpiresAt.Time, store.revoked[c.ID], time.Second)
+
+	// Then: the session is still valid
+	_, err = store.GetSession(ctx, "sub", c.SessionID)
+	assert.NoError(t, err)
+}
+
+func TestRevoke_Ignored(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	expired := Claims{RegisteredClaims: jwt.NewRegisteredClaims("sub", time.Hour)}
+	expired.ID = "TOKEN_ID"
+	expired.ExpiresAt = jwtgo.NewNumericDate(time.Now().Add(-time.Second))
+
+	// When: the store is not called
+	s := newTestService(failingStore{})
+
+	// Then: nothing to revoke
+	assert.NoError(t, s.Revoke(ctx, "INVALID_ACCESS_TOKEN"))
+	assert.NoError(t, s.RevokeToken(ctx, expired))
+
+	// Then: token issued before token IDs were introduced
+	assert.Equal(t, ErrTokenNotRevocable, s.RevokeToken(ctx, Claims{RegisteredClaims: jwt.NewRegisteredClaims("sub", time.Hour)}))
+}
+
+func TestRevoke_Error(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	c := Claims{RegisteredClaims: jwt.NewRegisteredClaims("sub", time.Hour)}
+	c.ID = "TOKEN_ID"
+
+	// When:
+	err := newTestService(failingStore{}).RevokeToken(context.Background(), c)
+
+	// Then:
+	assert.ErrorIs(t, err, ErrRedis)
+}
+
+func TestVerifyToken_Revoked(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc    string
+		revoked bool
+		expErr  error
+	}{
+		{desc: "not revoked"},
+		{desc: "revoked", revoked: true, expErr: jwt.ErrInvalidToken},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			store := NewMemoryStore()
+			s := newTestService(store)
+			token, err := s.Login(ctx, LoginRequest{Subject: "sub"})
+			require.NoError(t, err)
+			c, err := s.ParseToken(ctx, token.AccessToken)
+			require.NoError(t, err)
+			if tc.revoked {
+				require.NoError(t, store.RevokeToken(ctx, c.ID, c.Subject, time.Hour))
+			}
+
+			// When:
+			err = s.VerifyToken(ctx, token.AccessToken, c)
+
+			// Then:
+			assert.Equal(t, tc.expErr, err)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/timex"
+)
+
+/*
+StoredSession is a login of a subject as kept in the SessionStore, in the redis hash of the subject keyed by session ID
+
+	It will implement encoding.BinaryMarshaler and encoding.BinaryUnMarshaler so that go-redis can unmarshal it automatically
+*/
+type StoredSession struct {
+	// AccessToken is the token of sessions stored before tokens were hashed, see TokenHash
+	AccessToken string `json:"AccessToken,omitempty"`
+	// TokenHash is the keyed hash of the current access token of the session
+	TokenHash string    `json:"TokenHash,omitempty"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	ExpiresAt time.Time `json:"ExpiresAt"`
+	UserAgent string    `json:"UserAgent,omitempty"`
+	IP        string    `json:"IP,omitempty"`
+	// IdleTimeout is how long the session may be unused before it is logged out, it is not limited if it is 0
+	// It is kept in the session so that changing the configuration only applies to new sessions.
+	IdleTimeout time.Duration `json:"IdleTimeout,omitempty"`
+}
+
+func (v StoredSession) MarshalBinary() ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (v *StoredSession) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, &v)
+}
+
+// MaxSessionsFromEnv returns the maximum number of concurrent sessions per subject in AUTH_MAX_SESSIONS
+// The number of sessions is not limited if it is not configured.
+func MaxSessionsFromEnv() (int, error) {
+	v := os.Getenv("AUTH_MAX_SESSIONS")
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("AUTH_MAX_SESSIONS must be a non-negative integer: %s", v)
+	}
+
+	return n, nil
+}
+
+// SessionLifetimeFromEnv returns the absolute lifetime of a session in AUTH_SESSION_MAX_LIFETIME
+// It is 24h if not configured. The session is logged out when it ends, even if it is still in use.
+func SessionLifetimeFromEnv() (time.Duration, error) {
+	v := os.Getenv("AUTH_SESSION_MAX_LIFETIME")
+	if v == "" {
+		return defaultSessionLifetime, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.Wrap(err, "AUTH_SESSION_MAX_LIFETIME")
+	}
+	if d < tokenExpiryDuration {
+		return 0, errors.Errorf("AUTH_SESSION_MAX_LIFETIME must be at least the access token lifetime of %s", tokenExpiryDuration)
+	}
+
+	return d, nil
+}
+
+// IdleTimeoutFromEnv returns how long a session may be unused before it is logged out in AUTH_SESSION_IDLE_TIMEOUT
+// The session is not logged out when idle if it is not configured.
+func IdleTimeoutFromEnv() (time.Duration, error) {
+	v := os.Getenv("AUTH_SESSION_IDLE_TIMEOUT")
+	if v == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.Wrap(err, "AUTH_SESSION_IDLE_TIMEOUT")
+	}
+	if d < 0 {
+		return 0, errors.Errorf("AUTH_SESSION_IDLE_TIMEOUT must not be negative: %s", v)
+	}
+
+	return d, nil
+}
+
+// getSession returns the session of the subject, which is not found if it has expired
+func (s Service) getSession(ctx context.Context, subject, sessionID string) (StoredSession, error) {
+	v, err := s.store.GetSession(ctx, subject, sessionID)
+	if err != nil {
+		return StoredSession{}, err
+	}
+	if !timex.NowSGT().Before(v.ExpiresAt) {
+		return StoredSession{}, ErrSessionNotFound
+	}
+
+	return v, nil
+}
+
+// staleSessions returns the IDs of the expired sessions, and of the oldest sessions that must be evicted
+// so that a new session can be added without exceeding the maximum number of sessions
+func staleSessions(all map[string]StoredSession, maxSessions int, now time.Time) []string {
+	type entry struct {
+		id        string
+		createdAt time.Time
+	}
+
+	var result []string
+	var active []entry
+	for id, v := range all {
+		if !now.Before(v.ExpiresAt) {
+			result = append(result, id)
+			continue
+		}
+		active = append(active, entry{id: id, createdAt: v.CreatedAt})
+	}
+
+	if maxSessions <= 0 || len(active) < maxSessions {
+		return result
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].createdAt.Before(active[j].createdAt)
+	})
+	for _, it := range active[:len(active)-maxSessions+1] {
+		result = append(result, it.id)
+	}
+
+	return result
+}
@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slices"
+)
+
+func TestStaleSessions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	given := map[string]string{
+		"expired": newTestSession(t, now.Add(-2*time.Hour), now.Add(-time.Hour)),
+		"oldest":  newTestSession(t, now.Add(-3*time.Minute), now.Add(time.Hour)),
+		"older":   newTestSession(t, now.Add(-2*time.Minute), now.Add(time.Hour)),
+		"newest":  newTestSession(t, now.Add(-time.Minute), now.Add(time.Hour)),
+		"invalid": "INVALID",
+	}
+
+	testCases := []struct {
+		desc        string
+		maxSessions int
+		expected    []string
+	}{
+		{desc: "unlimited", maxSessions: 0, expected: []string{"expired", "invalid"}},
+		{desc: "within limit", maxSessions: 4, expected: []string{"expired", "invalid"}},
+		{desc: "at limit", maxSessions: 3, expected: []string{"expired", "invalid", "oldest"}},
+		{desc: "above limit", maxSessions: 2, expected: []string{"expired", "invalid", "oldest", "older"}},
+		{desc: "single session", maxSessions: 1, expected: []string{"expired", "invalid", "oldest", "older", "newest"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// When:
+			sessions, invalid := decodeSessions(given)
+			actual := append(invalid, staleSessions(sessions, tc.maxSessions, now)...)
+
+			// Then:
+			assert.ElementsMatch(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestLogin_MaxSessions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc        string
+		maxSessions int
+		expected    int
+	}{
+		{desc: "second session not allowed", maxSessions: 1, expected: 1},
+		{desc: "second session allowed", maxSessions: 2, expected: 2},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given: subject is logged in on another device already
+			ctx := context.Background()
+			subject := "sub"
+			s := newTestService(NewMemoryStore(), WithMaxSessions(tc.maxSessions))
+			laptop, err := s.Login(ctx, LoginRequest{Subject: subject})
+			require.NoError(t, err)
+
+			// When:
+			phone, err := s.Login(ctx, LoginRequest{Subject: subject})
+
+			// Then: the other session is logged out if the limit is reached
+			require.NoError(t, err)
+			c, err := s.ParseToken(ctx, phone.AccessToken)
+			require.NoError(t, err)
+			list, err := s.Sessions(ctx, c)
+			require.NoError(t, err)
+			assert.Len(t, list, tc.expected)
+			assert.Contains(t, sessionIDs(list), phone.SessionID)
+			assert.Equal(t, tc.expected == 2, slices.Contains(sessionIDs(list), laptop.SessionID))
+		})
+	}
+}
+
+func TestVerifyToken_Session(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	subject := "sub"
+	now := time.Now()
+	c := Claims{RegisteredClaims: jwt.NewRegisteredClaims(subject, time.Hour), SessionID: "phone"}
+	tokenString, err := testSigner.Sign(context.Background(), c)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc     string
+		given    *StoredSession
+		expected error
+	}{
+		{
+			desc:  "session found",
+			given: &StoredSession{TokenHash: testTokenHash(tokenString), CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		},
+		{
+			desc:     "session not found",
+			expected: jwt.ErrInvalidToken,
+		},
+		{
+			desc:     "session has another token",
+			given:    &StoredSession{TokenHash: testTokenHash("ANOTHER_TOKEN"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+			expected: jwt.ErrInvalidToken,
+		},
+		{
+			desc:     "session has expired",
+			given:    &StoredSession{TokenHash: testTokenHash(tokenString), CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Second)},
+			expected: jwt.ErrInvalidToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			store := NewMemoryStore()
+			if tc.given != nil {
+				require.NoError(t, store.SaveSession(ctx, subject, "phone", *tc.given, 0))
+			}
+
+			// When:
+			err := newTestService(store).VerifyToken(ctx, tokenString, c)
+
+			// Then:
+			assert.Equal(t, tc.expected, err)
+		})
+	}
+}
+
+func TestLogin_IdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Given: subject has a session that has been idle for too long
+	ctx := context.Background()
+	subject := "sub"
+	now := time.Now()
+	store := NewMemoryStore()
+	require.NoError(t, store.SaveSession(ctx, subject, "laptop", StoredSession{
+		TokenHash:   "HASH",
+		CreatedAt:   now.Add(-time.Hour),
+		ExpiresAt:   now.Add(time.Hour),
+		IdleTimeout: time.Minute,
+	}, 0))
+
+	// When:
+	actual, err := newTestService(store, WithIdleTimeout(10*time.Minute), WithSessionLifetime(time.Hour)).
+		Login(ctx, LoginRequest{Subject: subject})
+
+	// Then: the idle session is removed
+	require.NoError(t, err)
+	_, err = store.GetSession(ctx, subject, "laptop")
+	assert.Equal(t, ErrSessionNotFound, err)
+
+	// Then: the new session has an idle timeout and an absolute lifetime
+	saved, err := store.GetSession(ctx, subject, actual.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, saved.IdleTimeout)
+	assert.Equal(t, time.Hour, saved.ExpiresAt.Sub(saved.CreatedAt))
+}
+
+func TestVerifyToken_IdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	subject := "sub"
+	c := Claims{RegisteredClaims: jwt.NewRegisteredClaims(subject, time.Hour), SessionID: "phone"}
+	tokenString, err := testSigner.Sign(context.Background(), c)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc      string
+		createdAt time.Duration
+		expected  error
+	}{
+		{desc: "idle timeout extended", createdAt: -5 * time.Minute},
+		{desc: "idle for too long", createdAt: -20 * time.Minute, expected: jwt.ErrInvalidToken},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			now := time.Now()
+			store := NewMemoryStore()
+			require.NoError(t, store.SaveSession(ctx, subject, "phone", StoredSession{
+				TokenHash:   testTokenHash(tokenString),
+				CreatedAt:   now.Add(tc.createdAt),
+				ExpiresAt:   now.Add(time.Hour),
+				IdleTimeout: 10 * time.Minute,
+			}, 0))
+
+			// When:
+			err := newTestService(store).VerifyToken(ctx, tokenString, c)
+
+			// Then:
+			assert.Equal(t, tc.expected, err)
+			if tc.expected != nil {
+				return
+			}
+			list, err := store.ListSessions(ctx, subject)
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.WithinDuration(t, now.Add(10*time.Minute), list[0].IdleExpiresAt, time.Second)
+		})
+	}
+}
+
+func TestMaxSessionsFromEnv(t *testing.T) {
+	testCases := []struct {
+		given     string
+		expected  int
+		expectErr bool
+	}{
+		{given: "", expected: 0},
+		{given: "3", expected: 3},
+		{given: "-1", expectErr: true},
+		{given: "many", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.given, func(t *testing.T) {
+			// Given:
+			t.Setenv("AUTH_MAX_SESSIONS", tc.given)
+
+			// When:
+			actual, err := MaxSessionsFromEnv()
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func newTestSession(t *testing.T, createdAt, expiresAt time.Time) string {
+	t.Helper()
+
+	b, err := StoredSession{AccessToken: "ACCESS_TOKEN", CreatedAt: createdAt, ExpiresAt: expiresAt}.MarshalBinary()
+	require.NoError(t, err)
+
+	return string(b)
+}
+
+func TestSessionLifetimeFromEnv(t *testing.T) {
+	testCases := []struct {
+		given     string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{given: "", expected: defaultSessionLifetime},
+		{given: "720h", expected: 720 * time.Hour},
+		{given: "1m", expectErr: true},
+		{given: "month", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.given, func(t *testing.T) {
+			// Given:
+			t.Setenv("AUTH_SESSION_MAX_LIFETIME", tc.given)
+
+			// When:
+			actual, err := SessionLifetimeFromEnv()
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestIdleTimeoutFromEnv(t *testing.T) {
+	testCases := []struct {
+		given     string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{given: "", expected: 0},
+		{given: "30m", expected: 30 * time.Minute},
+		{given: "-1m", expectErr: true},
+		{given: "idle", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.given, func(t *testing.T) {
+			// Given:
+			t.Setenv("AUTH_SESSION_IDLE_TIMEOUT", tc.given)
+
+			// When:
+			actual, err := IdleTimeoutFromEnv()
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+// testTokenHash returns the hash of the token stored by the test service
+func testTokenHash(tokenString string) string {
+	return newTestService(nil).hashToken(tokenString)
+}
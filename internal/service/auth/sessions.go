@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/severedsea/golang-kit/logr"
+	"github.com/severedsea/golang-kit/web"
+)
+
+// lastSeenResolution is how stale the last seen time of a session may be, to avoid a write on every request
+const lastSeenResolution = time.Minute
+
+// Session is an active session of a subject
+type Session struct {
+	ID         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	// IdleExpiresAt is when the session is logged out unless it is used, it is zero if there is no idle timeout
+	IdleExpiresAt time.Time
+	UserAgent     string
+	IP            string
+	// Current is true for the session of the access token that listed the sessions
+	Current bool
+}
+
+// newSession returns the stored session as a Session which has not been seen since it was created
+func newSession(id string, v StoredSession) Session {
+	return Session{
+		ID:         id,
+		CreatedAt:  v.CreatedAt,
+		LastSeenAt: v.CreatedAt,
+		ExpiresAt:  v.ExpiresAt,
+		UserAgent:  v.UserAgent,
+		IP:         v.IP,
+	}
+}
+
+// Sessions returns the active sessions of the subject of the claims, the most recent first
+// The sessions that have expired or have been idle for too long are left out.
+func (s Service) Sessions(ctx context.Context, c Claims) ([]Session, error) {
+	result, err := s.store.ListSessions(ctx, c.Subject)
+	if err != nil {
+		return nil, web.NewError(ErrRedis, err.Error())
+	}
+
+	for i := range result {
+		result[i].Current = result[i].ID == c.SessionID
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+// RevokeSession logs out the session of the subject
+func (s Service) RevokeSession(ctx context.Context, subject, sessionID string) error {
+	n, err := s.store.DeleteSessions(ctx, subject, sessionID)
+	if err != nil {
+		return web.NewError(ErrRedis, err.Error())
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	s.cache.invalidate(ctx, cacheInvalidation{Subject: subject, SessionIDs: []string{sessionID}})
+
+	logr.GetLogger(ctx).
+		WithField("sid", sessionID).
+		Infof("session revoked")
+
+	return nil
+}
+
+// RevokeOtherSessions logs out every session of the subject of the claims except its own
+func (s Service) RevokeOtherSessions(ctx context.Context, c Claims) error {
+	sessions, err := s.store.ListSessions(ctx, c.Subject)
+	if err != nil {
+		return web.NewError(ErrRedis, err.Error())
+	}
+
+	var others []string
+	for _, it := range sessions {
+		if it.ID != c.SessionID {
+			others = append(others, it.ID)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+
+	if _, err := s.store.DeleteSessions(ctx, c.Subject, others...); err != nil {
+		return web.NewError(ErrRedis, err.Error())
+	}
+	s.cache.invalidate(ctx, cacheInvalidation{Subject: c.Subject, SessionIDs: others})
+
+	logr.GetLogger(ctx).
+		WithField("count", len(others)).
+		Infof("other sessions revoked")
+
+	return nil
+}
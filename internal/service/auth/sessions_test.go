@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	c := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: "sub"}, SessionID: "phone"}
+
+	store := NewMemoryStore()
+	require.NoError(t, store.SaveSession(ctx, "sub", "laptop", StoredSession{
+		TokenHash: "LAPTOP",
+		CreatedAt: now.Add(-time.Hour),
+		ExpiresAt: now.Add(time.Hour),
+		UserAgent: "Firefox",
+		IP:        "10.0.0.1",
+	}, 0))
+	require.NoError(t, store.SaveSession(ctx, "sub", "phone", StoredSession{
+		TokenHash: "PHONE",
+		CreatedAt: now.Add(-time.Minute),
+		ExpiresAt: now.Add(time.Hour),
+		UserAgent: "Safari",
+		IP:        "10.0.0.2",
+	}, 0))
+	require.NoError(t, store.SaveSession(ctx, "sub", "expired", StoredSession{
+		TokenHash: "EXPIRED",
+		CreatedAt: now.Add(-2 * time.Hour),
+		ExpiresAt: now.Add(-time.Hour),
+	}, 0))
+	store.sessions["sub"]["laptop"].lastSeenAt = now.Add(-5 * time.Minute)
+
+	// When:
+	actual, err := newTestService(store).Sessions(ctx, c)
+
+	// Then: most recent first
+	require.NoError(t, err)
+	require.Len(t, actual, 2)
+	assert.Equal(t, "phone", actual[0].ID)
+	assert.True(t, actual[0].Current)
+	assert.Equal(t, "Safari", actual[0].UserAgent)
+	assert.Equal(t, "10.0.0.2", actual[0].IP)
+	assert.True(t, now.Add(-time.Minute).Equal(actual[0].LastSeenAt), "not seen since it was created")
+
+	assert.Equal(t, "laptop", actual[1].ID)
+	assert.False(t, actual[1].Current)
+	assert.Equal(t, "Firefox", actual[1].UserAgent)
+	assert.True(t, now.Add(-time.Hour).Equal(actual[1].CreatedAt))
+	assert.True(t, now.Add(-5*time.Minute).Equal(actual[1].LastSeenAt))
+	assert.True(t, now.Add(time.Hour).Equal(actual[1].ExpiresAt))
+}
+
+func TestSessions_IdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	now := time.Now()
+	c := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: "sub"}, SessionID: "phone"}
+
+	store := NewMemoryStore()
+	require.NoError(t, store.SaveSession(ctx, "sub", "phone", StoredSession{TokenHash: "PHONE", CreatedAt: now.Add(-5 * time.Minute), ExpiresAt: now.Add(time.Hour), IdleTimeout: 10 * time.Minute}, 0))
+	require.NoError(t, store.SaveSession(ctx, "sub", "laptop", StoredSession{TokenHash: "LAPTOP", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour), IdleTimeout: 10 * time.Minute}, 0))
+
+	// When:
+	actual, err := newTestService(store).Sessions(ctx, c)
+
+	// Then: the idle session is left out
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, "phone", actual[0].ID)
+	assert.WithinDuration(t, now.Add(5*time.Minute), actual[0].IdleExpiresAt, time.Second)
+	assert.True(t, now.Add(time.Hour).Equal(actual[0].ExpiresAt), "absolute lifetime is not extended")
+}
+
+func TestSessions_Error(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	c := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: "sub"}, SessionID: "phone"}
+
+	// When:
+	_, err := newTestService(failingStore{}).Sessions(context.Background(), c)
+
+	// Then:
+	assert.ErrorIs(t, err, ErrRedis)
+}
+
+func TestRevokeSession(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc     string
+		given    string
+		expected error
+	}{
+		{desc: "revoked", given: "laptop"},
+		{desc: "not found", given: "tablet", expected: ErrSessionNotFound},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			now := time.Now()
+			store := NewMemoryStore()
+			require.NoError(t, store.SaveSession(ctx, "sub", "laptop", StoredSession{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0))
+
+			// When:
+			err := newTestService(store).RevokeSession(ctx, "sub", tc.given)
+
+			// Then:
+			assert.Equal(t, tc.expected, err)
+			_, err = store.GetSession(ctx, "sub", "laptop")
+			assert.Equal(t, tc.expected == nil, err == ErrSessionNotFound)
+		})
+	}
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	now := time.Now()
+	c := Claims{RegisteredClaims: jwtgo.RegisteredClaims{Subject: "sub"}, SessionID: "phone"}
+
+	store := NewMemoryStore()
+	for _, id := range []string{"laptop", "phone", "tablet"} {
+		require.NoError(t, store.SaveSession(ctx, "sub", id, StoredSession{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0))
+	}
+
+	// When:
+	err := newTestService(store).RevokeOtherSessions(ctx, c)
+
+	// Then: the current session is kept
+	require.NoError(t, err)
+	list, err := store.ListSessions(ctx, "sub")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"phone"}, sessionIDs(list))
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// StoreFromEnv returns the SessionStore in AUTH_SESSION_STORE, which is either `redis`, `memory` or `file`
+// It is redis if not configured. The redis client is only created for the redis store.
+// The file store is kept in AUTH_SESSION_STORE_PATH, and compacted every AUTH_SESSION_STORE_COMPACTION_INTERVAL.
+func StoreFromEnv(newRedis func() (redis.Cmdable, error)) (SessionStore, error) {
+	switch v := os.Getenv("AUTH_SESSION_STORE"); v {
+	case "", "redis":
+		rds, err := newRedis()
+		if err != nil {
+			return nil, errors.Wrap(err, "redis")
+		}
+
+		return NewRedisStore(rds), nil
+
+	case "memory":
+		return NewMemoryStore(), nil
+
+	case "file":
+		path := os.Getenv("AUTH_SESSION_STORE_PATH")
+		if path == "" {
+			return nil, errors.New("AUTH_SESSION_STORE_PATH is required for the file store")
+		}
+
+		interval := defaultCompactionInterval
+		if v := os.Getenv("AUTH_SESSION_STORE_COMPACTION_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, errors.Wrap(err, "AUTH_SESSION_STORE_COMPACTION_INTERVAL")
+			}
+			if d <= 0 {
+				return nil, errors.Errorf("AUTH_SESSION_STORE_COMPACTION_INTERVAL must be positive: %s", v)
+			}
+			interval = d
+		}
+
+		return OpenFileStore(path, interval)
+
+	default:
+		return nil, errors.Errorf("AUTH_SESSION_STORE must be redis, memory or file: %s", v)
+	}
+}
+
+// SessionStore keeps the sessions of the subjects, and the revoked and refresh tokens of the sessions
+// Sessions stored before session IDs were introduced have an empty session ID, only the redis store has them.
+type SessionStore interface {
+	// SaveSession stores a new session of the subject
+	// The ended sessions are removed, and the oldest sessions are evicted to stay within maxSessions if it is not 0.
+	SaveSession(ctx context.Context, subject, sessionID string, v StoredSession, maxSessions int) error
+	// GetSession returns the session of the subject, or ErrSessionNotFound
+	GetSession(ctx context.Context, subject, sessionID string) (StoredSession, error)
+	// UpdateSession replaces the session of the subject with the result of update, or returns ErrSessionNotFound
+	// It fails if the session has been changed or deleted in the meantime, so that it is never brought back after a logout.
+	UpdateSession(ctx context.Context, subject, sessionID string, update func(v *StoredSession) error) error
+	// TouchSession records that the session is used, which extends its idle timeout and updates its last seen time
+	// It fails if the session has been idle for too long.
+	TouchSession(ctx context.Context, subject, sessionID string, v StoredSession) error
+	// ListSessions returns the sessions of the subject that have not ended, in no particular order
+	ListSessions(ctx context.Context, subject string) ([]Session, error)
+	// DeleteSessions deletes the sessions of the subject and returns how many were deleted
+	DeleteSessions(ctx context.Context, subject string, sessionIDs ...string) (int, error)
+
+	// RevokeToken adds the token ID to the revocation list until ttl
+	RevokeToken(ctx context.Context, tokenID, subject string, ttl time.Duration) error
+	// IsRevoked checks if the token ID is in the revocation list
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+
+	// SaveRefreshToken stores the refresh token with the hash until it expires
+	SaveRefreshToken(ctx context.Context, tokenHash string, rt StoredRefreshToken) error
+	// GetRefreshToken returns the refresh token with the hash, or ErrInvalidGrant if it is unknown or has expired
+	GetRefreshToken(ctx context.Context, tokenHash string) (StoredRefreshToken, error)
+	// UseRefreshToken marks the refresh token with the hash as used until it expires
+	// It returns false if it was used already, and is atomic so that a refresh token cannot be used twice concurrently.
+	UseRefreshToken(ctx context.Context, tokenHash string, rt StoredRefreshToken) (bool, error)
+}
@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/severedsea/jwt-server/internal/pkg/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	testSessionStore(t, func(t *testing.T) SessionStore {
+		return NewMemoryStore()
+	})
+}
+
+func TestRedisStore(t *testing.T) {
+	t.Parallel()
+
+	// Given: redis configured with the REDIS_* env vars
+	rds, err := redis.New()
+	if err != nil || rds.Ping(context.Background()).Err() != nil {
+		t.Skip("redis is not available")
+	}
+
+	testSessionStore(t, func(t *testing.T) SessionStore {
+		return NewRedisStore(rds)
+	})
+}
+
+func TestStoreFromEnv(t *testing.T) {
+	// No connection is made until a command is sent
+	rds := goredis.NewClient(&goredis.Options{})
+	newRedis := func() (goredis.Cmdable, error) {
+		return rds, nil
+	}
+
+	testCases := []struct {
+		given     string
+		expected  SessionStore
+		expectErr bool
+	}{
+		{given: "", expected: RedisStore{redis: rds}},
+		{given: "redis", expected: RedisStore{redis: rds}},
+		{given: "memory", expected: NewMemoryStore()},
+		{given: "memcached", expectErr: true},
+		{given: "file", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.given, func(t *testing.T) {
+			// Given:
+			t.Setenv("AUTH_SESSION_STORE", tc.given)
+
+			// When:
+			actual, err := StoreFromEnv(newRedis)
+
+			// Then:
+			assert.Equal(t, tc.expectErr, err != nil)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+
+	t.Run("file", func(t *testing.T) {
+		// Given:
+		path := filepath.Join(t.TempDir(), "sessions.db")
+		t.Setenv("AUTH_SESSION_STORE", "file")
+		t.Setenv("AUTH_SESSION_STORE_PATH", path)
+		t.Setenv("AUTH_SESSION_STORE_COMPACTION_INTERVAL", "10m")
+
+		// When:
+		actual, err := StoreFromEnv(newRedis)
+
+		// Then:
+		require.NoError(t, err)
+		require.IsType(t, &FileStore{}, actual)
+		assert.NoError(t, actual.(*FileStore).Close())
+		assert.FileExists(t, path)
+
+		// When: the compaction interval is invalid
+		t.Setenv("AUTH_SESSION_STORE_COMPACTION_INTERVAL", "hourly")
+		_, err = StoreFromEnv(newRedis)
+
+		// Then:
+		assert.Error(t, err)
+	})
+}
+
+// testSessionStore is the conformance test suite that every SessionStore must pass
+// Every test uses a new subject so that the stores may be shared.
+func testSessionStore(t *testing.T, newStore func(t *testing.T) SessionStore) {
+	t.Helper()
+
+	newSubject := func(t *testing.T) string {
+		id, err := newRandomID()
+		require.NoError(t, err)
+
+		return "test_" + id
+	}
+
+	t.Run("save, get, list and delete", func(t *testing.T) {
+		t.Parallel()
+
+		// Given:
+		ctx := context.Background()
+		store := newStore(t)
+		subject := newSubject(t)
+		now := time.Now().Truncate(time.Second)
+		given := StoredSession{TokenHash: "HASH", CreatedAt: now, ExpiresAt: now.Add(time.Hour), UserAgent: "Firefox", IP: "10.0.0.1"}
+
+		// When:
+		require.NoError(t, store.SaveSession(ctx, subject, "laptop", given, 0))
+
+		// Then:
+		actual, err := store.GetSession(ctx, subject, "laptop")
+		require.NoError(t, err)
+		assert.Equal(t, "HASH", actual.TokenHash)
+		assert.True(t, given.CreatedAt.Equal(actual.CreatedAt))
+		assert.True(t, given.ExpiresAt.Equal(actual.ExpiresAt))
+		assert.Equal(t, "Firefox", actual.UserAgent)
+		assert.Equal(t, "10.0.0.1", actual.IP)
+
+		list, err := store.ListSessions(ctx, subject)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, "laptop", list[0].ID)
+		assert.True(t, now.Equal(list[0].LastSeenAt), "not seen since it was created")
+		assert.True(t, list[0].IdleExpiresAt.IsZero())
+
+		_, err = store.GetSession(ctx, subject, "phone")
+		assert.Equal(t, ErrSessionNotFound, err)
+
+		// When:
+		n, err := store.DeleteSessions(ctx, subject, "laptop")
+
+		// Then:
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		_, err = store.GetSession(ctx, subject, "laptop")
+		assert.Equal(t, ErrSessionNotFound, err)
+		n, err = store.DeleteSessions(ctx, subject, "laptop")
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("max sessions", func(t *testing.T) {
+		t.Parallel()
+
+		// Given:
+		ctx := context.Background()
+		store := newStore(t)
+		subject := newSubject(t)
+		now := time.Now()
+
+		// When:
+		for i, id := range []string{"oldest", "older", "newest"} {
+			v := StoredSession{CreatedAt: now.Add(time.Duration(i) * time.Minute), ExpiresAt: now.Add(time.Hour)}
+			require.NoError(t, store.SaveSession(ctx, subject, id, v, 2))
+		}
+
+		// Then: the oldest session is evicted
+		list, err := store.ListSessions(ctx, subject)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"older", "newest"}, sessionIDs(list))
+	})
+
+	t.Run("ended sessions", func(t *testing.T) {
+		t.Parallel()
+
+		// Given:
+		ctx := context.Background()
+		store := newStore(t)
+		subject := newSubject(t)
+		now := time.Now()
+		require.NoError(t, store.SaveSession(ctx, subject, "laptop", StoredSession{CreatedAt: now, ExpiresAt: now.Add(200 * time.Millisecond)}, 0))
+
+		// When:
+		time.Sleep(300 * time.Millisecond)
+
+		// Then:
+		list, err := store.ListSessions(ctx, subject)
+		require.NoError(t, err)
+		assert.Empty(t, list)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		t.Parallel()
+
+		// Given:
+		ctx := context.Background()
+		store := newStore(t)
+		subject := newSubject(t)
+		now := time.Now()
+		require.NoError(t, store.SaveSession(ctx, subject, "laptop", StoredSession{TokenHash: "HASH", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, 0))
+
+		// When:
+		err := store.UpdateSession(ctx, subject, "laptop", func(v *StoredSession) error {
+			v.TokenHash = "NEW_HASH"
+
+			return nil
+		})
+
+		// Then:
+		require.NoError(t, err)
+		actual, err := store.GetSession(ctx, subject, "laptop")
+		require.NoError(t, err)
+		assert.Equal(t, "NEW_HASH", actual.TokenHash)
+		assert.True(t, now.Add(time.Hour).Equal(actual.ExpiresAt))
+
+		// When: the update fails
+		givenErr := errors.New("something happened")
+		err = store.UpdateSession(ctx, subject, "laptop", func(v *StoredSession) error {
+			v.TokenHash = "ANOTHER_HASH"
+
+			return givenErr
+		})
+
+		// Then: the session is not changed
+		assert.Equal(t, givenErr, err)
+		actual, err = store.GetSession(ctx, subject, "laptop")
+		require.NoError(t, err)
+		assert.Equal(t, "NEW_HASH", actual.TokenHash)
+
+		// When: the session is logged out
+		_, err = store.DeleteSessions(ctx, subject, "laptop")
+		require.NoError(t, err)
+		err = store.UpdateSession(ctx, subject, "laptop", func(v *StoredSession) error {
+			return nil
+		})
+
+		// Then: it is not brought back
+		assert.Equal(t, ErrSessionNotFound, err)
+		_, err = store.GetSession(ctx, subject, "laptop")
+		assert.Equal(t, ErrSessionNotFound, err)
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		t.Parallel()
+
+		// Given:
+		ctx := context.Background()
+		store := newStore(t)
+		subject := newSubject(t)
+		now := time.Now()
+		v := StoredSession{CreatedAt: now, ExpiresAt: now.Add(time.Hour), IdleTimeout: 400 * time.Millisecond}
+		require.NoError(t, store.SaveSession(ctx, subject, "laptop", v, 0))
+
+		// When: the session is used
+		for i := 0; i < 3; i++ {
+			time.Sleep(200 * time.Millisecond)
+			require.NoError(t, store.TouchSession(ctx, subject, "laptop", v))
+		}
+
+		// Then: the idle timeout is extended
+		list, err := store.ListSessions(ctx, subject)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.WithinDuration(t, time.Now().Add(400*time.Millisecond), list[0].IdleExpiresAt, 100*time.Millisecond)
+		assert.True(t, now.Add(time.Hour).Equal(list[0].ExpiresAt), "absolute lifetime is not extended")
+
+		// When: the session is not used
+		time.Sleep(600 * time.Millisecond)
+
+		// Then:
+		assert.Error(t, store.TouchSession(ctx, subject, "laptop", v))
+		list, err = store.ListSessions(ctx, subject)
+		require.NoError(t, err)
+		assert.Empty(t, list)
+	})
+
+	t.Run("revoke token", func(t *testing.T) {
+		t.Parallel()
+
+		// Given:
+		ctx := context.Background()
+		store := newStore(t)
+		tokenID := newSubject(t)
+
+		// When:
+		require.NoError(t, store.RevokeToken(ctx, tokenID, "sub", time.Hour))
+
+		// Then:
+		revoked, err := store.IsRevoked(ctx, tokenID)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+		revoked, err = store.IsRevoked(ctx, tokenID+"_another")
+		require.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("refresh token", func(t *testing.T) {
+		t.Parallel()
+
+		// Given:
+		ctx := context.Background()
+		store := newStore(t)
+		tokenHash := newSubject(t)
+		given := StoredRefreshToken{Subject: "sub", SessionID: "laptop", Scopes: []string{"profile"}, ExpiresAt: time.Now().Add(time.Hour)}
+
+		// When:
+		require.NoError(t, store.SaveRefreshToken(ctx, tokenHash, given))
+
+		// Then:
+		actual, err := store.GetRefreshToken(ctx, tokenHash)
+		require.NoError(t, err)
+		assert.Equal(t, "laptop", actual.SessionID)
+		assert.Equal(t, []string{"profile"}, actual.Scopes)
+		_, err = store.GetRefreshToken(ctx, tokenHash+"_another")
+		assert.Equal(t, ErrInvalidGrant, err)
+
+		// Then: it can only be used once
+		ok, err := store.UseRefreshToken(ctx, tokenHash, given)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = store.UseRefreshToken(ctx, tokenHash, given)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func sessionIDs(sessions []Session) []string {
+	var result []string
+	for _, it := range sessions {
+		result = append(result, it.ID)
+	}
+
+	return result
+}
@@ -2,12 +2,14 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	jwtgo "github.com/golang-jwt/jwt/v4"
 	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+	"golang.org/x/exp/slices"
 )
 
 const (
@@ -15,6 +17,9 @@ const (
 	tokenExpiryDuration           = time.Duration(20) * time.Minute
 )
 
+// TokenLifetime is how long an issued token is valid
+const TokenLifetime = tokenExpiryDuration
+
 // TokenType is the enum for exemption
 type TokenType string
 
@@ -34,91 +39,217 @@ type Token struct {
 	TokenType   TokenType
 	ExpiresIn   int
 	ExpiresAt   time.Time
-	Scope       string
+	// Scope is the space-delimited list of granted scopes
+	Scope    string
+	Roles    []string
+	Audience string
+	// SessionID is the session of the subject that the token belongs to
+	SessionID string
+	// RefreshToken is the single-use opaque token that renews the session
+	RefreshToken string
 }
 
 // Claims is the claims for the JWT
 type Claims struct {
 	jwtgo.RegisteredClaims
-	// Add custom claims here if necessary
+	// Scope is the space-delimited list of granted scopes as defined in RFC 8693
+	Scope string `json:"scope,omitempty"`
+	// Roles is the list of granted roles as defined in RFC 9068
+	Roles []string `json:"roles,omitempty"`
+	// SessionID is the session of the subject that the token belongs to, as defined in OpenID Connect
+	SessionID string `json:"sid,omitempty"`
 }
 
-/*
-redisValue is the value for storing token related data in redis
-
-	It will implement encoding.BinaryMarshaler and encoding.BinaryUnMarshaler so that go-redis can unmarshal it automatically
-
-https://github.com/go-redis/redis/issues/739#issuecomment-418185046
-*/
-type redisValue struct {
-	AccessToken string `redis:"AccessToken"`
+// Scopes returns the granted scopes
+func (c Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
 }
 
-func (v redisValue) MarshalBinary() ([]byte, error) {
-	return json.Marshal(v)
+// HasScope checks if the scope is granted
+func (c Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
 }
 
-func (v *redisValue) UnmarshalBinary(data []byte) error {
-	return json.Unmarshal(data, &v)
+// HasRole checks if the role is granted
+func (c Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
 }
 
-func (s Service) GenerateToken(ctx context.Context, subject string) (Token, error) {
-	// Generate claims
-	c := Claims{
-		RegisteredClaims: jwt.NewRegisteredClaims(subject, tokenExpiryDuration),
+// GenerateToken signs the claims for the login request and stores the token as a new session
+// The session can be renewed with the refresh token until it expires or has been idle for too long.
+// The scopes and roles are not checked against the grants of the subject.
+func (s Service) GenerateToken(ctx context.Context, req LoginRequest) (Token, error) {
+	subject := req.Subject
+
+	sessionID, err := newRandomID()
+	if err != nil {
+		return Token{}, err
 	}
 
-	// Sign claims
-	tokenString, err := jwt.Sign(c)
+	t, c, err := s.signAccessToken(ctx, req, sessionID)
 	if err != nil {
 		return Token{}, err
 	}
+	expiresAt := c.IssuedAt.Add(s.sessionLifetime)
+
+	// Save token as a new session, without touching the other sessions of the subject
+	if err := s.store.SaveSession(ctx, subject, sessionID, StoredSession{
+		TokenHash:   s.hashToken(t.AccessToken),
+		CreatedAt:   c.IssuedAt.Time,
+		ExpiresAt:   expiresAt,
+		UserAgent:   req.UserAgent,
+		IP:          req.IP,
+		IdleTimeout: s.idleTimeout,
+	}, s.maxSessions); err != nil {
+		return Token{}, err
+	}
+	// The oldest sessions of the subject may have been logged out to make room
+	if s.maxSessions > 0 {
+		s.cache.invalidate(ctx, cacheInvalidation{Subject: subject})
+	}
 
-	// Save token to redis
-	key := redisKey(subject)
-	if err := s.redis.SetArgs(ctx, key,
-		redisValue{
-			AccessToken: tokenString,
-		},
-		// [20210827] ExpiresAt is only available on redis >= 6.2, we're using AWS Elasticache 6.0.5
-		redis.SetArgs{TTL: tokenExpiryDuration},
-	).Err(); err != nil {
+	t.RefreshToken, err = s.saveRefreshToken(ctx, StoredRefreshToken{
+		Subject:   subject,
+		SessionID: sessionID,
+		Scopes:    c.Scopes(),
+		Roles:     c.Roles,
+		Audience:  req.Audience,
+		ExpiresAt: s.refreshTokenExpiry(c.IssuedAt.Time, expiresAt),
+	})
+	if err != nil {
 		return Token{}, err
 	}
 
+	return t, nil
+}
+
+// signAccessToken signs a new access token of the session
+func (s Service) signAccessToken(ctx context.Context, req LoginRequest, sessionID string) (Token, Claims, error) {
+	tokenID, err := newRandomID()
+	if err != nil {
+		return Token{}, Claims{}, err
+	}
+
+	// Generate claims
+	c := Claims{
+		RegisteredClaims: jwt.NewRegisteredClaims(req.Subject, tokenExpiryDuration, audience(req.Audience)...),
+		Scope:            strings.Join(dedupe(req.Scopes), " "),
+		Roles:            dedupe(req.Roles),
+	}
+	c.ID = tokenID
+	c.SessionID = sessionID
+
+	// Sign claims
+	tokenString, err := s.signer.Sign(ctx, c)
+	if err != nil {
+		return Token{}, Claims{}, err
+	}
+
 	return Token{
 		AccessToken: tokenString,
 		ExpiresIn:   int(tokenExpiryDuration.Seconds()),
 		ExpiresAt:   time.Unix(c.ExpiresAt.Unix(), 0),
 		TokenType:   tokenTypeBearer,
-	}, nil
+		Scope:       c.Scope,
+		Roles:       c.Roles,
+		Audience:    req.Audience,
+		SessionID:   sessionID,
+	}, c, nil
 }
 
 // ParseToken validates and parses the token string
+// The token must be issued for the audience of the service, if set.
 func (s Service) ParseToken(_ context.Context, tokenString string) (Claims, error) {
+	v := s.verifier
+	if s.audience != "" {
+		v = v.ForAudience(s.audience)
+	}
+
 	c := Claims{}
-	if err := jwt.Parse(tokenString, &c); err != nil {
+	if err := v.Parse(tokenString, &c); err != nil {
 		return Claims{}, err
 	}
 
 	return c, nil
 }
 
-// VerifyToken verifies the token against the one stored in redis
-func (s Service) VerifyToken(ctx context.Context, tokenString, subject string) error {
-	// check if token in redis is equal
-	var v redisValue
-	if err := s.redis.Get(ctx, redisKey(subject)).Scan(&v); err != nil {
+// VerifyToken verifies that the token is not revoked and that it is the one stored for its session
+// Only the hash of the token is stored, sessions stored before tokens were hashed are migrated when they are used.
+// Tokens issued before sessions were introduced are not migrated, they are kept as is until they expire.
+// Tokens that were verified recently are not looked up again if there is a VerifyCache.
+func (s Service) VerifyToken(ctx context.Context, tokenString string, c Claims) error {
+	tokenHash := s.hashToken(tokenString)
+	cached, generation := s.cache.get(tokenHash)
+	if cached {
+		return nil
+	}
+
+	if err := s.verifyToken(ctx, tokenString, c); err != nil {
+		return err
+	}
+	s.cache.add(tokenHash, c, generation)
+
+	return nil
+}
+
+// verifyToken verifies the token against the store
+func (s Service) verifyToken(ctx context.Context, tokenString string, c Claims) error {
+	// Tokens issued before token IDs were introduced cannot be revoked individually
+	if c.ID != "" {
+		revoked, err := s.store.IsRevoked(ctx, c.ID)
+		if err != nil || revoked {
+			return jwt.ErrInvalidToken
+		}
+	}
+
+	// Tokens issued before sessions were introduced are stored as the single token of the subject
+	if c.SessionID == "" {
+		v, err := s.store.GetSession(ctx, c.Subject, "")
+		if err != nil || !s.matchToken(v, tokenString) {
+			return jwt.ErrInvalidToken
+		}
+
+		return nil
+	}
+
+	// check if token of the session is equal
+	v, err := s.getSession(ctx, c.Subject, c.SessionID)
+	if err != nil {
+		return jwt.ErrInvalidToken
+	}
+
+	if !s.matchToken(v, tokenString) {
 		return jwt.ErrInvalidToken
 	}
+	if v.TokenHash == "" {
+		s.migrateSession(ctx, c.Subject, c.SessionID)
+	}
 
-	if v.AccessToken != tokenString {
+	if err := s.store.TouchSession(ctx, c.Subject, c.SessionID, v); err != nil {
 		return jwt.ErrInvalidToken
 	}
 
 	return nil
 }
 
-func redisKey(subject string) string {
-	return "auth_" + subject
+// newRandomID returns a random ID for the `jti` and `sid` claims
+func newRandomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// dedupe removes the empty and duplicate values while keeping the order
+func dedupe(values []string) []string {
+	var result []string
+	for _, it := range values {
+		if it != "" && !slices.Contains(result, it) {
+			result = append(result, it)
+		}
+	}
+
+	return result
 }
@@ -2,15 +2,13 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
 	"testing"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/severedsea/golang-kit/appconfig"
 	"github.com/severedsea/jwt-server/internal/pkg/jwt"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestToken_IsValid(t *testing.T) {
@@ -48,15 +46,9 @@ func TestParseToken(t *testing.T) {
 	assert.NoError(t, err)
 	subject := "123"
 
-	// Mocks:
-	mockRds := &mockRedis{}
-	mockRds.On("SetArgs", mock.Anything, redisKey(subject),
-		mock.AnythingOfType("redisValue"), mock.AnythingOfType("redis.SetArgs")).
-		Return(redis.NewStatusResult("", nil))
-
-	s := New(mockRds)
+	s := newTestService(NewMemoryStore())
 	// gen a new Token
-	exp, err := s.GenerateToken(ctx, subject)
+	exp, err := s.GenerateToken(ctx, LoginRequest{Subject: subject})
 	assert.NoError(t, err)
 
 	// When:
@@ -73,6 +65,67 @@ func TestParseToken(t *testing.T) {
 	assert.Equal(t, iat.UnixNano(), act.IssuedAt.UnixNano())
 }
 
+func TestParseToken_Audience(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc     string
+		given    []string
+		audience string
+		expErr   error
+	}{
+		{desc: "issued for the audience", given: []string{"orders"}, audience: "orders"},
+		{desc: "issued for several audiences", given: []string{"profile", "orders"}, audience: "orders"},
+		{desc: "issued for another audience", given: []string{"profile"}, audience: "orders", expErr: jwt.ErrInvalidToken},
+		{desc: "issued without audience", audience: "orders", expErr: jwt.ErrInvalidToken},
+		{desc: "audience not enforced", given: []string{"profile"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			tokenString, err := testSigner.Sign(ctx, Claims{
+				RegisteredClaims: jwt.NewRegisteredClaims("sub", time.Hour, tc.given...),
+			})
+			assert.NoError(t, err)
+
+			// When:
+			s := newTestService(nil, WithAudience(tc.audience))
+			_, err = s.ParseToken(ctx, tokenString)
+
+			// Then:
+			assert.Equal(t, tc.expErr, err)
+
+			// When: route group of another audience
+			_, err = s.ForAudience("payments").ParseToken(ctx, tokenString)
+
+			// Then:
+			assert.Equal(t, jwt.ErrInvalidToken, err)
+		})
+	}
+}
+
+func TestParseToken_Issuer(t *testing.T) {
+	t.Parallel()
+
+	// Given: token issued by another issuer with the same keys
+	ctx := context.Background()
+	c := Claims{RegisteredClaims: jwt.NewRegisteredClaims("sub", time.Hour)}
+	c.Issuer = "another"
+	tokenString, err := testSigner.Sign(ctx, c)
+	assert.NoError(t, err)
+
+	// When:
+	_, err = newTestService(nil).ParseToken(ctx, tokenString)
+
+	// Then:
+	assert.Equal(t, jwt.ErrInvalidToken, err)
+}
+
 func TestParseToken_Error(t *testing.T) {
 	t.Parallel()
 
@@ -80,7 +133,7 @@ func TestParseToken_Error(t *testing.T) {
 	ctx := context.Background()
 
 	// When:
-	s := New(nil)
+	s := newTestService(nil)
 	act, err := s.ParseToken(ctx, "INVALID_ACCESS_TOKEN")
 
 	// Then:
@@ -94,69 +147,34 @@ func TestVerifyToken(t *testing.T) {
 
 	// Given:
 	ctx := context.Background()
-	subject := "4321"
-	expClaims := Claims{
-		RegisteredClaims: jwt.NewRegisteredClaims(subject, time.Hour),
-	}
-	tokenString, err := jwt.Sign(expClaims)
-	assert.NoError(t, err)
-
-	b, err := json.Marshal(redisValue{AccessToken: tokenString})
-	assert.NoError(t, err)
-
-	// Mocks:
-	mockRds := &mockRedis{}
-	mockRds.On("Get", mock.Anything, redisKey(subject)).
-		Return(redis.NewStringResult(string(b), nil))
+	s := newTestService(NewMemoryStore())
+	token, err := s.GenerateToken(ctx, LoginRequest{Subject: "4321"})
+	require.NoError(t, err)
+	c, err := s.ParseToken(ctx, token.AccessToken)
+	require.NoError(t, err)
 
 	// When:
-	s := New(mockRds)
-	err = s.VerifyToken(ctx, tokenString, subject)
+	err = s.VerifyToken(ctx, token.AccessToken, c)
 
 	// Then:
 	assert.NoError(t, err)
-
-	// Assert mocks call
-	mockRds.AssertNumberOfCalls(t, "Get", 1)
 }
 
 func TestVerifyToken_Error(t *testing.T) {
 	t.Parallel()
 
 	subject := "1234"
-	expClaims := Claims{
-		RegisteredClaims: jwt.NewRegisteredClaims(subject, time.Hour),
-	}
-	tokenString, err := jwt.Sign(expClaims)
-	assert.NoError(t, err)
+	c := Claims{RegisteredClaims: jwt.NewRegisteredClaims(subject, time.Hour), SessionID: "phone"}
+	c.ID = "TOKEN_ID"
+	tokenString, err := testSigner.Sign(context.Background(), c)
+	require.NoError(t, err)
 
 	testCases := []struct {
-		desc     string
-		given    string
-		expCalls int
-		exp      error
-		mock     func(rds *mockRedis)
+		desc  string
+		store SessionStore
 	}{
-		{
-			desc:     "Redis error",
-			given:    tokenString,
-			expCalls: 1,
-			exp:      jwt.ErrInvalidToken,
-			mock: func(rds *mockRedis) {
-				rds.On("Get", mock.Anything, redisKey(subject)).
-					Return(redis.NewStringResult("", redis.ErrClosed))
-			},
-		},
-		{
-			desc:     "JWT Token is not equal",
-			given:    tokenString,
-			expCalls: 1,
-			exp:      jwt.ErrInvalidToken,
-			mock: func(rds *mockRedis) {
-				rds.On("Get", mock.Anything, redisKey(subject)).
-					Return(redis.NewStringResult("NOT THE SAME TOKEN", nil))
-			},
-		},
+		{desc: "store error", store: failingStore{}},
+		{desc: "session not stored", store: NewMemoryStore()},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -166,21 +184,12 @@ func TestVerifyToken_Error(t *testing.T) {
 			// Given:
 			ctx := context.Background()
 
-			// Mocks:
-			mockRds := &mockRedis{}
-			tc.mock(mockRds)
-
 			// When:
-			s := New(mockRds)
-			err := s.VerifyToken(ctx, tc.given, subject)
+			s := newTestService(tc.store)
+			err := s.VerifyToken(ctx, tokenString, c)
 
 			// Then:
-			assert.Error(t, err)
-			assert.ErrorIs(t, err, tc.exp)
-			assert.Equal(t, tc.exp, err)
-
-			// Assert mocks call
-			mockRds.AssertNumberOfCalls(t, "Get", tc.expCalls)
+			assert.Equal(t, jwt.ErrInvalidToken, err)
 		})
 	}
 }
@@ -193,83 +202,76 @@ func TestGenerateToken(t *testing.T) {
 
 	subject := "123"
 
-	mockRds := &mockRedis{}
-	s := New(mockRds)
+	store := NewMemoryStore()
+	s := newTestService(store)
 
 	expClaims := Claims{
 		RegisteredClaims: jwt.NewRegisteredClaims(subject, tokenExpiryDuration),
 	}
-	expTokenString, err := jwt.Sign(expClaims)
-	assert.NoError(t, err)
-
-	// Mocks:
-	mockRds.On("SetArgs", mock.Anything, redisKey(subject),
-		redisValue{
-			AccessToken: expTokenString,
-		},
-		redis.SetArgs{TTL: tokenExpiryDuration}).
-		Return(redis.NewStatusResult("", nil))
 
 	// When:
-	act, err := s.GenerateToken(ctx, subject)
+	act, err := s.GenerateToken(ctx, LoginRequest{Subject: subject})
 
 	// Then:
 	assert.NoError(t, err)
-	assert.Equal(t, Token{
-		AccessToken: expTokenString,
-		TokenType:   tokenTypeBearer,
-		ExpiresIn:   int(tokenExpiryDuration.Seconds()),
-		ExpiresAt:   time.Unix(expClaims.ExpiresAt.Unix(), 0),
-	}, act)
-
-	// Assert mocks call
-	mockRds.AssertNumberOfCalls(t, "SetArgs", 1)
+	assert.Equal(t, tokenTypeBearer, act.TokenType)
+	assert.Equal(t, int(tokenExpiryDuration.Seconds()), act.ExpiresIn)
+	assert.Equal(t, time.Unix(expClaims.ExpiresAt.Unix(), 0), act.ExpiresAt)
+
+	// Then: token has a unique id and session
+	actClaims, err := s.ParseToken(ctx, act.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, subject, actClaims.Subject)
+	assert.Len(t, actClaims.ID, 22, "base64url encoded 16 random bytes")
+	assert.Len(t, actClaims.SessionID, 22, "base64url encoded 16 random bytes")
+	assert.Equal(t, act.SessionID, actClaims.SessionID)
+	list, err := store.ListSessions(ctx, subject)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.True(t, actClaims.IssuedAt.Equal(list[0].LastSeenAt))
+	saved, err := store.GetSession(ctx, subject, act.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, testTokenHash(act.AccessToken), saved.TokenHash, "only the hash of the token is stored")
+	assert.Empty(t, saved.AccessToken)
+	assert.True(t, actClaims.IssuedAt.Equal(saved.CreatedAt))
+	assert.True(t, actClaims.IssuedAt.Add(defaultSessionLifetime).Equal(saved.ExpiresAt), "session can be renewed until it expires")
+	assert.Zero(t, saved.IdleTimeout)
+	assert.NotEmpty(t, act.RefreshToken)
+
+	another, err := s.GenerateToken(ctx, LoginRequest{Subject: subject})
+	assert.NoError(t, err)
+	anotherClaims, err := s.ParseToken(ctx, another.AccessToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, actClaims.ID, anotherClaims.ID)
+	assert.NotEqual(t, actClaims.SessionID, anotherClaims.SessionID)
 }
 
 func TestGenerateToken_Error(t *testing.T) {
-	mockRds := &mockRedis{}
-	subject := "ID_NO"
+	t.Parallel()
 
-	testCases := []struct {
-		desc  string
-		mocks func(ctx context.Context) context.Context
-		exp   error
-	}{
-		{
-			desc: "redis set error",
-			mocks: func(ctx context.Context) context.Context {
-				var err error
-				ctx, err = appconfig.LoadFromEnv(ctx)
-				assert.NoError(t, err)
-
-				mockRds.On("SetArgs", mock.Anything, redisKey(subject),
-					mock.AnythingOfType("redisValue"), mock.AnythingOfType("redis.SetArgs")).
-					Return(redis.NewStatusResult("", redis.Nil))
-
-				return ctx
-			},
-			exp: redis.Nil,
-		},
-	}
-	for _, tc := range testCases {
-		tc := tc
-		t.Run(tc.desc, func(t *testing.T) {
-			t.Parallel()
+	// Given:
+	ctx, err := appconfig.LoadFromEnv(context.Background())
+	require.NoError(t, err)
 
-			// Given:
-			ctx := context.Background()
+	// When:
+	act, err := newTestService(failingStore{}).GenerateToken(ctx, LoginRequest{Subject: "ID_NO"})
+
+	// Then:
+	assert.Error(t, err)
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, errTestStore)
+}
 
-			// Mocks:
-			ctx = tc.mocks(ctx)
+func TestClaims_HasScope_HasRole(t *testing.T) {
+	t.Parallel()
 
-			// When:
-			s := New(mockRds)
-			act, err := s.GenerateToken(ctx, subject)
+	// Given:
+	c := Claims{Scope: "orders:read  profile", Roles: []string{"admin"}}
 
-			// Then:
-			assert.Error(t, err)
-			assert.Empty(t, act)
-			assert.ErrorIs(t, err, tc.exp)
-		})
-	}
+	// Then:
+	assert.Equal(t, []string{"orders:read", "profile"}, c.Scopes())
+	assert.True(t, c.HasScope("profile"))
+	assert.False(t, c.HasScope("orders"))
+	assert.True(t, c.HasRole("admin"))
+	assert.False(t, c.HasRole("user"))
 }
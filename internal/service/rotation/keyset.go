@@ -0,0 +1,201 @@
+package rotation
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+)
+
+const (
+	keySetRedisKey = "jwt_keys"
+)
+
+// storedKey is a key in the key set stored in redis
+type storedKey struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	// PublicKey is the PKIX DER encoded public key
+	PublicKey []byte `json:"public_key"`
+	// PrivateKey is the AES-GCM encrypted PKCS #8 DER encoded private key
+	PrivateKey  []byte    `json:"private_key"`
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatesAt time.Time `json:"activates_at"`
+}
+
+/*
+keySet is the value for storing the keys in redis, ordered by activation time
+
+	It will implement encoding.BinaryMarshaler and encoding.BinaryUnMarshaler so that go-redis can unmarshal it automatically
+*/
+type keySet struct {
+	Keys []storedKey `json:"keys"`
+}
+
+func (v keySet) MarshalBinary() ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (v *keySet) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, &v)
+}
+
+// active returns the index of the latest key that has been activated, or -1 if there is none
+func (v keySet) active(now time.Time) int {
+	result := -1
+	for i, it := range v.Keys {
+		if !it.ActivatesAt.After(now) {
+			result = i
+		}
+	}
+
+	return result
+}
+
+// add adds the key while keeping the keys ordered by activation time
+func (v *keySet) add(k storedKey) {
+	v.Keys = append(v.Keys, k)
+	sort.SliceStable(v.Keys, func(i, j int) bool {
+		return v.Keys[i].ActivatesAt.Before(v.Keys[j].ActivatesAt)
+	})
+}
+
+// prune removes the keys that have been retired for longer than the retention period
+// A key is retired when the next key is activated.
+func (v *keySet) prune(now time.Time, retention time.Duration) bool {
+	var keys []storedKey
+	for i, it := range v.Keys {
+		if i+1 < len(v.Keys) && !v.Keys[i+1].ActivatesAt.Add(retention).After(now) {
+			continue
+		}
+		keys = append(keys, it)
+	}
+
+	pruned := len(keys) != len(v.Keys)
+	v.Keys = keys
+
+	return pruned
+}
+
+func (s *Service) loadKeySet(ctx context.Context) (keySet, error) {
+	var v keySet
+	if err := s.redis.Get(ctx, keySetRedisKey).Scan(&v); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return keySet{}, nil
+		}
+
+		return keySet{}, err
+	}
+
+	return v, nil
+}
+
+func (s *Service) saveKeySet(ctx context.Context, v keySet) error {
+	return s.redis.Set(ctx, keySetRedisKey, v, 0).Err()
+}
+
+// newStoredKey generates a new key that is used for signing from the activation time
+func (s *Service) newStoredKey(activatesAt time.Time) (storedKey, error) {
+	key, err := jwt.GenerateKey(s.cfg.Algorithm)
+	if err != nil {
+		return storedKey{}, err
+	}
+
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(key.PublicKey)
+	if err != nil {
+		return storedKey{}, err
+	}
+	privKeyBytes, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return storedKey{}, err
+	}
+	encrypted, err := s.encrypt(privKeyBytes, key.ID)
+	if err != nil {
+		return storedKey{}, err
+	}
+
+	return storedKey{
+		ID:          key.ID,
+		Algorithm:   key.Method.Alg(),
+		PublicKey:   pubKeyBytes,
+		PrivateKey:  encrypted,
+		CreatedAt:   s.now(),
+		ActivatesAt: activatesAt,
+	}, nil
+}
+
+// toKey converts the stored key, decrypting the private key only if required
+func (s *Service) toKey(k storedKey, withPrivateKey bool) (jwt.Key, error) {
+	pubKey, err := x509.ParsePKIXPublicKey(k.PublicKey)
+	if err != nil {
+		return jwt.Key{}, err
+	}
+
+	var signKey crypto.Signer
+	if withPrivateKey {
+		b, err := s.decrypt(k.PrivateKey, k.ID)
+		if err != nil {
+			return jwt.Key{}, err
+		}
+		privKey, err := x509.ParsePKCS8PrivateKey(b)
+		if err != nil {
+			return jwt.Key{}, err
+		}
+		signer, ok := privKey.(crypto.Signer)
+		if !ok {
+			return jwt.Key{}, errors.New("unsupported private key type")
+		}
+		signKey = signer
+	}
+
+	return jwt.NewKey(k.Algorithm, signKey, pubKey)
+}
+
+// encrypt seals the plaintext with AES-GCM, binding it to the key ID
+func (s *Service) encrypt(plaintext []byte, kid string) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, []byte(kid)), nil
+}
+
+// decrypt opens the ciphertext sealed by encrypt
+func (s *Service) decrypt(ciphertext []byte, kid string) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("encrypted private key is too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, sealed, []byte(kid))
+}
+
+func (s *Service) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,52 @@
+package rotation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySet_Prune(t *testing.T) {
+	t.Parallel()
+
+	// Given: the next key is activated, but a replica only picks it up on its next reload
+	lifetime := 20 * time.Minute
+	leeway := 30 * time.Second
+	reloadInterval := 30 * time.Second
+	checkInterval := time.Minute
+	retention := Retention(lifetime, leeway, reloadInterval, checkInterval)
+	activatesAt := testNow
+	lastSignedAt := activatesAt.Add(reloadInterval)
+
+	testCases := []struct {
+		desc     string
+		given    time.Time
+		expected int
+	}{
+		{desc: "last token signed with the retired key not expired", given: lastSignedAt.Add(lifetime + leeway - time.Second), expected: 2},
+		{desc: "rotation noticed one check interval late", given: lastSignedAt.Add(checkInterval + lifetime + leeway - time.Second), expected: 2},
+		{desc: "retention over", given: activatesAt.Add(retention), expected: 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			v := keySet{Keys: []storedKey{
+				{ID: "retired", ActivatesAt: activatesAt.Add(-24 * time.Hour)},
+				{ID: "active", ActivatesAt: activatesAt},
+			}}
+
+			// When:
+			pruned := v.prune(tc.given, retention)
+
+			// Then:
+			assert.Equal(t, tc.expected == 1, pruned)
+			assert.Len(t, v.Keys, tc.expected)
+			assert.Equal(t, "active", v.Keys[len(v.Keys)-1].ID)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package rotation
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockRedis is the mock redis
+type mockRedis struct {
+	mock.Mock
+	redis.Cmdable
+}
+
+func (m *mockRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	args := m.Called(ctx, key)
+
+	return args.Get(0).(*redis.StringCmd)
+}
+
+func (m *mockRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	args := m.Called(ctx, key, value, expiration)
+
+	return args.Get(0).(*redis.StatusCmd)
+}
+
+func (m *mockRedis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	args := m.Called(ctx, key, value, expiration)
+
+	return args.Get(0).(*redis.BoolCmd)
+}
+
+func (m *mockRedis) EvalSha(ctx context.Context, sha1 string, keys []string, a ...interface{}) *redis.Cmd {
+	args := m.Called(ctx, sha1, keys, a)
+
+	return args.Get(0).(*redis.Cmd)
+}
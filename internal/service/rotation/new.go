@@ -0,0 +1,91 @@
+// Package rotation generates and rotates the JWT signing keys stored in redis
+package rotation
+
+import (
+	"encoding/base64"
+	"os"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/envvar"
+)
+
+const (
+	defaultPrePublish = 10 * time.Minute
+)
+
+// Config is the key rotation schedule
+type Config struct {
+	// Algorithm is the signing algorithm of the generated keys
+	Algorithm string
+	// Interval is how long each key is used for signing
+	Interval time.Duration
+	// PrePublish is how long a new key is published for verification before it is used for signing
+	// This should be longer than the time clients cache the JWKS.
+	PrePublish time.Duration
+	// Retention is how long a retired key stays verifiable
+	// This must be at least the lifetime of the tokens it signed, see Retention.
+	Retention time.Duration
+	// EncryptionKey is the AES-256 key that encrypts the private keys stored in redis
+	EncryptionKey []byte
+}
+
+// Retention returns how long a retired key must stay verifiable
+// Replicas keep signing with the retired key until they reload the keys, and the rotation may be noticed
+// one check interval late, so the tokens it signed expire that much later than its retirement, plus the clock skew leeway.
+func Retention(tokenLifetime, leeway, reloadInterval, checkInterval time.Duration) time.Duration {
+	return tokenLifetime + leeway + reloadInterval + checkInterval
+}
+
+// ConfigFromEnv returns the key rotation schedule from the JWT_* env vars
+// Retention is not configurable as it depends on the token lifetime.
+func ConfigFromEnv() (Config, error) {
+	interval, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATION_INTERVAL"))
+	if err != nil {
+		return Config{}, errors.Wrap(err, "JWT_KEY_ROTATION_INTERVAL")
+	}
+
+	prePublish, err := time.ParseDuration(envvar.Get("JWT_KEY_PREPUBLISH", defaultPrePublish.String()))
+	if err != nil {
+		return Config{}, errors.Wrap(err, "JWT_KEY_PREPUBLISH")
+	}
+
+	encryptionKey, err := base64.StdEncoding.DecodeString(os.Getenv("JWT_KEY_ENCRYPTION_KEY"))
+	if err != nil {
+		return Config{}, errors.Wrap(err, "JWT_KEY_ENCRYPTION_KEY")
+	}
+
+	return Config{
+		Algorithm:     os.Getenv("JWT_SIGNING_ALG"),
+		Interval:      interval,
+		PrePublish:    prePublish,
+		EncryptionKey: encryptionKey,
+	}, nil
+}
+
+// New creates a new Service struct
+func New(rds redis.Cmdable, cfg Config) (*Service, error) {
+	if cfg.Interval <= cfg.PrePublish {
+		return nil, errors.New("rotation interval must be longer than the pre-publish window")
+	}
+	if cfg.Retention <= 0 {
+		return nil, errors.New("retention must be positive")
+	}
+	if len(cfg.EncryptionKey) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes")
+	}
+
+	return &Service{
+		redis: rds,
+		cfg:   cfg,
+		now:   time.Now,
+	}, nil
+}
+
+// Service holds the methods for this package
+type Service struct {
+	redis redis.Cmdable
+	cfg   Config
+	now   func() time.Time
+}
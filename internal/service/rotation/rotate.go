@@ -0,0 +1,133 @@
+package rotation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/severedsea/golang-kit/logr"
+)
+
+const (
+	lockRedisKey = "jwt_keys_lock"
+	lockTTL      = 30 * time.Second
+)
+
+// unlockScript deletes the lock only if it is still held by the same owner
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Rotate publishes the next key ahead of its activation and removes the keys whose tokens have all expired
+// Only the replica holding the redis lock rotates. It returns false if the lock is held by another replica.
+func (s *Service) Rotate(ctx context.Context) (bool, error) {
+	owner, err := s.lock(ctx)
+	if err != nil || owner == "" {
+		return false, err
+	}
+	defer s.unlock(ctx, owner)
+
+	v, err := s.loadKeySet(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "load keys")
+	}
+
+	now := s.now()
+	changed := v.prune(now, s.cfg.Retention)
+
+	if activatesAt, ok := s.nextActivation(v, now); ok {
+		k, err := s.newStoredKey(activatesAt)
+		if err != nil {
+			return false, errors.Wrap(err, "generate key")
+		}
+		v.add(k)
+		changed = true
+
+		logr.GetLogger(ctx).
+			WithField("kid", k.ID).
+			WithField("activates_at", k.ActivatesAt).
+			Infof("[rotation] Key published")
+	}
+
+	if !changed {
+		return true, nil
+	}
+
+	if err := s.saveKeySet(ctx, v); err != nil {
+		return false, errors.Wrap(err, "save keys")
+	}
+
+	return true, nil
+}
+
+// nextActivation returns the activation time of the next key if it is due to be published
+func (s *Service) nextActivation(v keySet, now time.Time) (time.Time, bool) {
+	// No key to sign with yet, so the first key is activated immediately
+	if len(v.Keys) == 0 {
+		return now, true
+	}
+
+	latest := v.Keys[len(v.Keys)-1]
+	activatesAt := latest.ActivatesAt.Add(s.cfg.Interval)
+	if now.Before(activatesAt.Add(-s.cfg.PrePublish)) {
+		return time.Time{}, false
+	}
+
+	// Keep the full pre-publish window even if the rotation is overdue
+	if earliest := now.Add(s.cfg.PrePublish); activatesAt.Before(earliest) {
+		activatesAt = earliest
+	}
+
+	return activatesAt, true
+}
+
+// Run rotates the keys on every check interval until the context is done
+func (s *Service) Run(ctx context.Context, checkInterval time.Duration) {
+	logger := logr.GetLogger(ctx)
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := s.Rotate(ctx); err != nil {
+			logger.Errorf("[rotation] Keys not rotated: %s", err)
+		}
+	}
+}
+
+// lock acquires the rotation lock and returns the owner token, which is empty if the lock is held by another replica
+func (s *Service) lock(ctx context.Context) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	owner := hex.EncodeToString(b)
+
+	ok, err := s.redis.SetNX(ctx, lockRedisKey, owner, lockTTL).Result()
+	if err != nil {
+		return "", errors.Wrap(err, "lock")
+	}
+	if !ok {
+		return "", nil
+	}
+
+	return owner, nil
+}
+
+func (s *Service) unlock(ctx context.Context, owner string) {
+	if err := unlockScript.Run(ctx, s.redis, []string{lockRedisKey}, owner).Err(); err != nil {
+		logr.GetLogger(ctx).Errorf("[rotation] Lock not released: %s", err)
+	}
+}
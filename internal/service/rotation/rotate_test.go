@@ -0,0 +1,316 @@
+package rotation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testNow    = time.Date(2023, 8, 27, 12, 0, 0, 0, time.UTC)
+	testConfig = Config{
+		Algorithm:     "ES256",
+		Interval:      24 * time.Hour,
+		PrePublish:    10 * time.Minute,
+		Retention:     20 * time.Minute,
+		EncryptionKey: []byte("0123456789abcdef0123456789abcdef"),
+	}
+)
+
+func TestRotate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc     string
+		given    []time.Time
+		expected []time.Time
+	}{
+		{
+			desc:     "no keys: activate immediately",
+			given:    nil,
+			expected: []time.Time{testNow},
+		},
+		{
+			desc:     "not due",
+			given:    []time.Time{testNow.Add(-23 * time.Hour)},
+			expected: nil,
+		},
+		{
+			desc:  "due: publish ahead of activation",
+			given: []time.Time{testNow.Add(-23*time.Hour - 50*time.Minute)},
+			expected: []time.Time{
+				testNow.Add(-23*time.Hour - 50*time.Minute),
+				testNow.Add(10 * time.Minute),
+			},
+		},
+		{
+			desc:  "overdue: keep the pre-publish window",
+			given: []time.Time{testNow.Add(-48 * time.Hour)},
+			expected: []time.Time{
+				testNow.Add(-48 * time.Hour),
+				testNow.Add(10 * time.Minute),
+			},
+		},
+		{
+			desc: "prune retired keys after retention",
+			given: []time.Time{
+				testNow.Add(-48 * time.Hour),
+				testNow.Add(-24*time.Hour - 10*time.Minute),
+				testNow.Add(-10 * time.Minute),
+			},
+			expected: []time.Time{
+				testNow.Add(-24*time.Hour - 10*time.Minute),
+				testNow.Add(-10 * time.Minute),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			ctx := context.Background()
+			s := newTestService(t, nil)
+			given := keySet{}
+			for _, it := range tc.given {
+				k, err := s.newStoredKey(it)
+				require.NoError(t, err)
+				given.add(k)
+			}
+
+			// Mocks:
+			mockRds := mockRotationRedis(t, given, true)
+			s.redis = mockRds
+			var actual keySet
+			mockRds.On("Set", mock.Anything, keySetRedisKey, mock.AnythingOfType("keySet"), time.Duration(0)).
+				Run(func(args mock.Arguments) { actual = args.Get(2).(keySet) }).
+				Return(redis.NewStatusResult("OK", nil))
+
+			// When:
+			ok, err := s.Rotate(ctx)
+
+			// Then:
+			require.NoError(t, err)
+			assert.True(t, ok)
+			mockRds.AssertNumberOfCalls(t, "EvalSha", 1)
+			if tc.expected == nil {
+				mockRds.AssertNotCalled(t, "Set")
+				return
+			}
+
+			var activations []time.Time
+			for _, it := range actual.Keys {
+				activations = append(activations, it.ActivatesAt)
+				assert.NotEmpty(t, it.ID)
+				assert.Equal(t, "ES256", it.Algorithm)
+			}
+			assert.Equal(t, tc.expected, activations)
+		})
+	}
+}
+
+func TestRotate_Locked(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	mockRds := mockRotationRedis(t, keySet{}, false)
+	s := newTestService(t, mockRds)
+
+	// When:
+	ok, err := s.Rotate(ctx)
+
+	// Then:
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	mockRds.AssertNotCalled(t, "Get")
+	mockRds.AssertNotCalled(t, "EvalSha")
+}
+
+func TestRotate_Error(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	ctx := context.Background()
+	mockRds := &mockRedis{}
+	mockRds.On("SetNX", mock.Anything, lockRedisKey, mock.Anything, lockTTL).
+		Return(redis.NewBoolResult(true, nil))
+	mockRds.On("Get", mock.Anything, keySetRedisKey).
+		Return(redis.NewStringResult("", redis.ErrClosed))
+	mockRds.On("EvalSha", mock.Anything, mock.Anything, []string{lockRedisKey}, mock.Anything).
+		Return(redis.NewCmdResult(int64(1), nil))
+	s := newTestService(t, mockRds)
+
+	// When:
+	ok, err := s.Rotate(ctx)
+
+	// Then:
+	assert.ErrorIs(t, err, redis.ErrClosed)
+	assert.False(t, ok)
+	mockRds.AssertNumberOfCalls(t, "EvalSha", 1)
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	// Given: retired, active and pre-published keys
+	s := newTestService(t, nil)
+	given := keySet{}
+	for _, it := range []time.Duration{-25 * time.Hour, -time.Hour, 5 * time.Minute} {
+		k, err := s.newStoredKey(testNow.Add(it))
+		require.NoError(t, err)
+		given.add(k)
+	}
+	s.redis = mockRotationRedis(t, given, true)
+
+	// When:
+	actual, err := s.Load()
+
+	// Then:
+	require.NoError(t, err)
+	assert.Equal(t, given.Keys[1].ID, actual.SigningKey().ID)
+	assert.NotNil(t, actual.SigningKey().PrivateKey)
+	assert.Equal(t, "ES256", actual.SigningKey().Method.Alg())
+
+	keys := actual.Keys()
+	require.Len(t, keys, 3)
+	assert.Equal(t, given.Keys[2].ID, keys[1].ID, "pre-published key is verifiable")
+	assert.Equal(t, given.Keys[0].ID, keys[2].ID, "retired key is verifiable")
+	for _, it := range keys[1:] {
+		assert.Nil(t, it.PrivateKey)
+		_, ok := actual.VerificationKey(it.ID)
+		assert.True(t, ok)
+	}
+}
+
+func TestLoad_Error(t *testing.T) {
+	t.Parallel()
+
+	s := newTestService(t, nil)
+	pending, err := s.newStoredKey(testNow.Add(time.Minute))
+	require.NoError(t, err)
+	active, err := s.newStoredKey(testNow.Add(-time.Minute))
+	require.NoError(t, err)
+	wrongKey := newTestService(t, nil)
+	wrongKey.cfg.EncryptionKey = []byte("fedcba9876543210fedcba9876543210")
+
+	testCases := []struct {
+		desc  string
+		s     *Service
+		given keySet
+	}{
+		{desc: "no keys", s: s, given: keySet{}},
+		{desc: "no active key", s: s, given: keySet{Keys: []storedKey{pending}}},
+		{desc: "wrong encryption key", s: wrongKey, given: keySet{Keys: []storedKey{active}}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			// Given:
+			tc.s.redis = mockRotationRedis(t, tc.given, true)
+
+			// When:
+			actual, err := tc.s.Load()
+
+			// Then:
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
+
+func TestNew_Error(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc  string
+		given func(cfg *Config)
+	}{
+		{desc: "interval shorter than pre-publish", given: func(cfg *Config) { cfg.Interval = cfg.PrePublish }},
+		{desc: "no retention", given: func(cfg *Config) { cfg.Retention = 0 }},
+		{desc: "invalid encryption key", given: func(cfg *Config) { cfg.EncryptionKey = []byte("short") }},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Given:
+			cfg := testConfig
+			tc.given(&cfg)
+
+			// When:
+			actual, err := New(nil, cfg)
+
+			// Then:
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	t.Parallel()
+
+	// Given:
+	s := newTestService(t, nil)
+	given := []byte("private key")
+
+	// When:
+	encrypted, err := s.encrypt(given, "kid")
+	require.NoError(t, err)
+
+	// Then:
+	assert.NotContains(t, string(encrypted), string(given))
+	actual, err := s.decrypt(encrypted, "kid")
+	assert.NoError(t, err)
+	assert.Equal(t, given, actual)
+
+	_, err = s.decrypt(encrypted, "another kid")
+	assert.Error(t, err, "ciphertext is bound to the key id")
+}
+
+func newTestService(t *testing.T, rds redis.Cmdable) *Service {
+	t.Helper()
+
+	s, err := New(rds, testConfig)
+	require.NoError(t, err)
+	s.now = func() time.Time { return testNow }
+
+	return s
+}
+
+// mockRotationRedis mocks the lock and the key set stored in redis
+func mockRotationRedis(t *testing.T, v keySet, locked bool) *mockRedis {
+	t.Helper()
+
+	m := &mockRedis{}
+	m.On("SetNX", mock.Anything, lockRedisKey, mock.Anything, lockTTL).
+		Return(redis.NewBoolResult(locked, nil))
+	m.On("EvalSha", mock.Anything, mock.Anything, []string{lockRedisKey}, mock.Anything).
+		Return(redis.NewCmdResult(int64(1), nil))
+
+	if len(v.Keys) == 0 {
+		m.On("Get", mock.Anything, keySetRedisKey).
+			Return(redis.NewStringResult("", redis.Nil))
+
+		return m
+	}
+
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	m.On("Get", mock.Anything, keySetRedisKey).
+		Return(redis.NewStringResult(string(b), nil))
+
+	return m
+}
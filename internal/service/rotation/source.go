@@ -0,0 +1,46 @@
+package rotation
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/severedsea/jwt-server/internal/pkg/jwt"
+)
+
+var _ jwt.KeySource = (*Service)(nil)
+
+// Load implements jwt.KeySource by loading the key ring from redis
+// The latest activated key is the signing key. The keys published ahead of their activation and
+// the retired keys are verification-only keys.
+func (s *Service) Load() (*jwt.KeyRing, error) {
+	v, err := s.loadKeySet(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "load keys")
+	}
+
+	now := s.now()
+	active := v.active(now)
+	if active < 0 {
+		return nil, errors.New("no active key in redis")
+	}
+
+	signKey, err := s.toKey(v.Keys[active], true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "active key %s", v.Keys[active].ID)
+	}
+
+	others := make([]jwt.Key, 0, len(v.Keys)-1)
+	for i := len(v.Keys) - 1; i >= 0; i-- {
+		if i == active {
+			continue
+		}
+
+		key, err := s.toKey(v.Keys[i], false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %s", v.Keys[i].ID)
+		}
+		others = append(others, key)
+	}
+
+	return jwt.NewKeyRing(signKey, others...)
+}